@@ -22,7 +22,12 @@ along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
 package config
 
 import (
+	"errors"
+	"miningPoolCli/utils/helpers"
+	"path/filepath"
 	"regexp"
+	"strconv"
+	"sync"
 	"time"
 )
 
@@ -43,10 +48,72 @@ type minerGetter struct {
 	MacSettings struct {
 		ReleaseURL, FileName, ExecutableName, ExecutableNameCuda string
 	}
-	ExecNamePref       string // "./" in linux; "" in win
 	CurrExecNameOpenCL string // current ExecutableName (depends on OS)
 	CurrExecNameCuda   string // current ExecutableName (depends on OS)
 	// StartPath          string // depends on OS
+
+	ManifestURL string // signed release manifest, see getminer.Manifest
+	ManifestKey string // hex ed25519 public key of the manifest signer
+	Update      bool   // install the manifest's release if it's not current
+	Rollback    bool   // switch back to the previously installed release
+	SkipVerify  bool   // don't check the miner directory against SHA256SUMS
+}
+
+// user overrides for the miner executables and their command line
+type minerOverrides struct {
+	OpenCLPath, CudaPath string // custom executables instead of the bundled ones
+
+	Args, ArgsOpenCL, ArgsCuda string // extra args for all gpus / per backend
+
+	GpuArgs helpers.IndexedFlag // gpu index -> extra args
+	GpuEnv  helpers.IndexedFlag // gpu index -> KEY=VALUE environment variables
+}
+
+// runtime directories, resolved at startup
+type paths struct {
+	DataDir   string // stats, server address, logs and other runtime files
+	MinerDir  string // miner executables
+	LogFile   string // optional copy of the log, relative to DataDir
+	SysfsRoot string // "/sys", used to read PCI devices and hwmon sensors
+}
+
+// which of the discovered gpus are used for mining, see gpuwrk.SelectGpus
+type gpuSelect struct {
+	Include, Exclude  []string // device ids, model substrings or PCI bus ids
+	IntegratedModels  []string // model substrings of integrated GPUs / APUs
+	IncludeIntegrated bool
+
+	// backend to mine with when a gpu is seen by CUDA and OpenCL:
+	// "auto" (CUDA), "cuda" or "opencl"; per PCI bus id and the default
+	Backend      string
+	BackendByBus map[string]string
+}
+
+// self-update of miningPoolCli, see selfupdate.Feed
+type updateSettings struct {
+	FeedURL   string
+	PublicKey string // hex ed25519 public key of the release signer
+	Auto      bool
+	Interval  time.Duration
+}
+
+// gpu sensors, see telemetry.Collector; an empty command path disables it
+type telemetrySettings struct {
+	NvidiaSmi string
+	RocmSmi   string
+	Interval  time.Duration // 0 disables polling
+}
+
+// thermal / power guard, see gpuwrk.CheckThermal; 0 disables a limit
+type thermal struct {
+	MaxTemp    int // °C, the miner is paused or throttled at this temperature
+	Hysteresis int // °C below the max temperature to resume
+	MaxPower   int // W, resumed below 90% of it
+
+	Throttle int    // BoostFactor used instead of pausing, 0 pauses
+	AlertCmd string // run when the guard trips or clears
+
+	GpuMaxTemp helpers.IndexedFlag // gpu index -> max temperature
 }
 
 type os struct {
@@ -55,6 +122,7 @@ type os struct {
 
 type serverSettings struct {
 	MiningPoolServerURL, AuthKey string
+	Worker                       string // sent as Worker-Name
 }
 
 type staticBeforeMinerSettings struct {
@@ -70,7 +138,7 @@ type osType struct {
 
 type minerRegexKit struct {
 	FindGPUPat, ReplaceStartGPU, ReplaceEndGPU,
-	FindIntIds, FindHashRate, FindDecimal *regexp.Regexp
+	FindIntIds, FindHashRate, FindDecimal, FindBusId *regexp.Regexp
 }
 
 // miner server config
@@ -79,10 +147,27 @@ type netServer struct {
 	HostFileName string
 	RunThis      bool
 	HandleKill   bool
+	Listen       helpers.ListFlag // -stat-listen, "host:port" or "unix:/path"
+
+	Token        string // bearer token required by the server
+	BasicAuth    string // "user:password" accepted instead of the token
+	TLSCert      string // serve https with this certificate and key
+	TLSKey       string
+	AllowedHosts []string // IPs / CIDRs allowed besides loopback
+
+	// Claymore compatible JSON-RPC API, disabled if the address is empty
+	ClaymoreAddr     string
+	ClaymorePassword string
 }
 
 var Colors colors
 var MinerGetter minerGetter
+var MinerOverrides minerOverrides
+var Paths paths
+var UpdateSettings updateSettings
+var GpuSelect gpuSelect
+var Telemetry telemetrySettings
+var Thermal thermal
 var OS os
 var ServerSettings serverSettings
 var StaticBeforeMinerSettings staticBeforeMinerSettings
@@ -90,10 +175,117 @@ var OSType osType
 var MRgxKit minerRegexKit
 
 var UpdateStatsFile bool
+var StatsFormats []string // -stats-format, written next to stats.json
+var DiscoverOnly bool
+var TUI bool // -tui, interactive terminal dashboard instead of the log
+
+// HistoryFile keeps the hashrate history across restarts, relative to
+// DataDir; empty keeps it in memory only
+var HistoryFile string
+
+// RediscoverInterval is how often the gpus are discovered again, 0 disables
+var RediscoverInterval time.Duration
 var StartProgramTimestamp int64
 
 var NetSrv netServer
 
+// Mu guards the settings ApplyReloadable replaces while the miners run:
+// GpuSelect, MinerOverrides, Thermal and File. Gpu discovery reads them
+// without it, its caller keeps it from running during a reload.
+var Mu sync.RWMutex
+
+// Settings is what the command line and the -config / -hive-config files
+// set. The flags are parsed into a staged Settings that is applied as a
+// whole at startup; a reload applies only the reloadable part.
+type Settings struct {
+	ServerSettings serverSettings
+	NetSrv         netServer
+	MinerGetter    minerGetter
+	MinerOverrides minerOverrides
+	Paths          paths
+	UpdateSettings updateSettings
+	GpuSelect      gpuSelect
+	Telemetry      telemetrySettings
+	Thermal        thermal
+	File           fileConfig
+
+	ConfigFile         string
+	UpdateStatsFile    bool
+	StatsFormats       []string
+	DiscoverOnly       bool
+	TUI                bool
+	HistoryFile        string
+	RediscoverInterval time.Duration
+}
+
+// Apply makes s the configuration of the program
+func (s *Settings) Apply() {
+	ServerSettings, NetSrv, MinerGetter = s.ServerSettings, s.NetSrv, s.MinerGetter
+	Paths, UpdateSettings, Telemetry = s.Paths, s.UpdateSettings, s.Telemetry
+	ConfigFile, UpdateStatsFile, StatsFormats = s.ConfigFile, s.UpdateStatsFile, s.StatsFormats
+	DiscoverOnly, TUI, HistoryFile = s.DiscoverOnly, s.TUI, s.HistoryFile
+	RediscoverInterval = s.RediscoverInterval
+	s.ApplyReloadable()
+}
+
+// ApplyReloadable replaces the gpu selection, the miner overrides, the
+// thermal guard and the -config file; the pool, server, directory and
+// telemetry settings the client was started with are kept
+func (s *Settings) ApplyReloadable() {
+	Mu.Lock()
+	defer Mu.Unlock()
+	GpuSelect, MinerOverrides, Thermal, File = s.GpuSelect, s.MinerOverrides, s.Thermal, s.File
+}
+
+// Check validates the -miner-args* and -gpu-args values
+func (o minerOverrides) Check() error {
+	for _, check := range []struct{ name, value string }{
+		{"-miner-args", o.Args},
+		{"-miner-args-cuda", o.ArgsCuda},
+		{"-miner-args-opencl", o.ArgsOpenCL},
+	} {
+		if _, err := helpers.SplitArgs(check.value); err != nil {
+			return errors.New("invalid " + check.name + ": " + err.Error())
+		}
+	}
+	for i, values := range o.GpuArgs {
+		for _, value := range values {
+			if _, err := helpers.SplitArgs(value); err != nil {
+				return errors.New("invalid -gpu-args for gpu " + strconv.Itoa(i) + ": " + err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+// Enabled tells whether any limit of the thermal guard is set
+func (t thermal) Enabled() bool {
+	return t.MaxTemp > 0 || t.MaxPower > 0 || len(t.GpuMaxTemp) > 0
+}
+
+// GpuMaxTempOf returns the -gpu-max-temp of the gpu at index, 0 if none
+func (t thermal) GpuMaxTempOf(index int) (int, error) {
+	temps := t.GpuMaxTemp[index]
+	if len(temps) == 0 {
+		return 0, nil
+	}
+	maxTemp, err := strconv.Atoi(temps[len(temps)-1])
+	if err != nil || maxTemp <= 0 {
+		return 0, errors.New("invalid -gpu-max-temp for gpu " + strconv.Itoa(index) + ": " + temps[len(temps)-1])
+	}
+	return maxTemp, nil
+}
+
+// Check validates the -gpu-max-temp values
+func (t thermal) Check() error {
+	for i := range t.GpuMaxTemp {
+		if _, err := t.GpuMaxTempOf(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func Configure() {
 	// -------- minerRegexKit
 	MRgxKit = minerRegexKit{
@@ -103,6 +295,7 @@ func Configure() {
 		FindIntIds:      regexp.MustCompile(`#\d[\d,]*`),
 		FindHashRate:    regexp.MustCompile(`instant speed: (\d+\.?\d*) Mhash\/s`),
 		FindDecimal:     regexp.MustCompile(`(\d+\.?\d*)`),
+		FindBusId:       regexp.MustCompile(`(?i)\b(?:[0-9a-f]{4,8}:)?[0-9a-f]{2}:[0-9a-f]{2}\.[0-7]\b`),
 	}
 	// --------
 
@@ -122,46 +315,117 @@ func Configure() {
 	}
 	// --------
 
-	MinerGetter.MinerDirectory = "miner_blob"
+	// -------- StaticBeforeMinerSettings
+	StaticBeforeMinerSettings = staticBeforeMinerSettings{
+		BoostFactor: 512,
+		Iterations:  "9223372036854775807",
+		TimeoutT:    15,
+	}
+	// --------
+
+	defaults := DefaultSettings()
+	defaults.Apply()
+
+	// -------- configure texts
+	configureTexts()
+	// --------
+}
+
+// DefaultSettings returns the settings of a command line without flags
+func DefaultSettings() Settings {
+	var s Settings
+
+	s.MinerGetter.MinerDirectory = "miner_blob"
+
+	s.MinerGetter.ManifestURL = "https://ton.ninja/miners/manifest.json"
 
 	// -------- set Release for Ubuntu
-	MinerGetter.UbuntuSettings.FileName = "minertools-cuda-ubuntu-18.04-x86-64.tar.gz"
-	MinerGetter.UbuntuSettings.ReleaseURL = "https://ton.ninja/miners/" +
-		MinerGetter.UbuntuSettings.FileName
-	MinerGetter.UbuntuSettings.ExecutableName = "pow-miner-opencl"
-	MinerGetter.UbuntuSettings.ExecutableNameCuda = "pow-miner-cuda"
+	s.MinerGetter.UbuntuSettings.FileName = "minertools-cuda-ubuntu-18.04-x86-64.tar.gz"
+	s.MinerGetter.UbuntuSettings.ReleaseURL = "https://ton.ninja/miners/" +
+		s.MinerGetter.UbuntuSettings.FileName
+	s.MinerGetter.UbuntuSettings.ExecutableName = "pow-miner-opencl"
+	s.MinerGetter.UbuntuSettings.ExecutableNameCuda = "pow-miner-cuda"
 	// --------
 
 	// -------- set Release for Win
-	MinerGetter.WinSettings.FileName = "minertools-cuda-windows-x86-64.zip"
-	MinerGetter.WinSettings.ReleaseURL = "https://ton.ninja/miners/" +
-		MinerGetter.WinSettings.FileName
-	MinerGetter.WinSettings.ExecutableName = "pow-miner-opencl.exe"
-	MinerGetter.WinSettings.ExecutableNameCuda = "pow-miner-cuda.exe"
+	s.MinerGetter.WinSettings.FileName = "minertools-cuda-windows-x86-64.zip"
+	s.MinerGetter.WinSettings.ReleaseURL = "https://ton.ninja/miners/" +
+		s.MinerGetter.WinSettings.FileName
+	s.MinerGetter.WinSettings.ExecutableName = "pow-miner-opencl.exe"
+	s.MinerGetter.WinSettings.ExecutableNameCuda = "pow-miner-cuda.exe"
 	// --------
 
 	// -------- set Release for Mac
-	MinerGetter.MacSettings.FileName = ""
-	MinerGetter.MacSettings.ReleaseURL = "https://raw.githubusercontent.com/TrueCarry/JettonGramGpuMiner/main/pow-miner-opencl-macos"
-	MinerGetter.MacSettings.ExecutableName = "pow-miner-opencl-macos"
+	s.MinerGetter.MacSettings.FileName = ""
+	s.MinerGetter.MacSettings.ReleaseURL = "https://raw.githubusercontent.com/TrueCarry/JettonGramGpuMiner/main/pow-miner-opencl-macos"
+	s.MinerGetter.MacSettings.ExecutableName = "pow-miner-opencl-macos"
 	// --------
 
-	// -------- StaticBeforeMinerSettings
-	StaticBeforeMinerSettings = staticBeforeMinerSettings{
-		BoostFactor: 512,
-		Iterations:  "9223372036854775807",
-		TimeoutT:    15,
+	s.Paths.SysfsRoot = "/sys"
+
+	s.MinerOverrides = minerOverrides{
+		GpuArgs: helpers.IndexedFlag{},
+		GpuEnv:  helpers.IndexedFlag{},
+	}
+
+	// -------- integrated GPUs are not used unless -include-integrated
+	s.GpuSelect = gpuSelect{
+		Backend:      "auto",
+		BackendByBus: map[string]string{},
+		IntegratedModels: []string{
+			"intel",
+			"gfx700",
+			"gfx703",
+			"gfx705",
+			"gfx801",
+			"gfx810",
+			"gfx902",
+			"gfx909",
+			"gfx90c",
+			"gfx1013",
+			"gfx1033",
+			"gfx1035",
+			"gfx1036",
+			"gfx1103",
+			"gfx1150",
+			"gfx1151",
+		},
+	}
+	// --------
+
+	s.Telemetry = telemetrySettings{
+		NvidiaSmi: "nvidia-smi",
+		RocmSmi:   "rocm-smi",
+		Interval:  10 * time.Second,
+	}
+
+	s.Thermal = thermal{
+		Hysteresis: 10,
+		GpuMaxTemp: helpers.IndexedFlag{},
+	}
+
+	// -------- Self-update
+	s.UpdateSettings = updateSettings{
+		FeedURL:  "https://ton.ninja/miningPoolCli/feed.json",
+		Interval: 6 * time.Hour,
 	}
 	// --------
 
 	// -------- Net server
-	NetSrv = netServer{
+	s.NetSrv = netServer{
 		Host:         "127.0.0.1",
 		HostFileName: "serveraddr.txt",
 	}
 	// --------
 
-	// -------- configure texts
-	configureTexts()
-	// --------
+	return s
+}
+
+// DataPath returns the location of a runtime file inside the data directory.
+// Absolute names are returned unchanged.
+func DataPath(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(Paths.DataDir, name)
 }
@@ -0,0 +1,95 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+)
+
+// fileConfig is the JSON file given with -config. Flags given on the
+// command line take precedence over the file.
+type fileConfig struct {
+	Gpus              []string `json:"gpus"`
+	ExcludeGpus       []string `json:"exclude_gpus"`
+	IntegratedGpus    []string `json:"integrated_gpus"` // replaces the built-in list
+	IncludeIntegrated *bool    `json:"include_integrated"`
+
+	Backend      string            `json:"backend"`     // "auto", "cuda" or "opencl"
+	BackendByBus map[string]string `json:"gpu_backend"` // PCI bus id -> backend
+
+	Devices []ManualDevice `json:"devices"` // replaces discovery when set
+}
+
+// ManualDevice is a gpu given in the config file for rigs where the miner
+// output can't be parsed
+type ManualDevice struct {
+	Backend    string `json:"backend"` // "cuda" or "opencl"
+	PlatformId int    `json:"platform_id"`
+	DeviceId   int    `json:"device_id"`
+	Label      string `json:"label"`
+	BusId      string `json:"bus_id"`
+}
+
+var File fileConfig
+
+// ConfigFile is the path given with -config
+var ConfigFile string
+
+// LoadFile reads the -config file into s. flagsSet holds the names of the
+// flags given on the command line, they take precedence over the file.
+func (s *Settings) LoadFile(path string, flagsSet map[string]bool) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	s.File = fileConfig{}
+	if err := json.Unmarshal(data, &s.File); err != nil {
+		return err
+	}
+
+	file, sel := s.File, &s.GpuSelect
+	if len(file.Gpus) > 0 && !flagsSet["gpus"] {
+		sel.Include = file.Gpus
+	}
+	if len(file.ExcludeGpus) > 0 && !flagsSet["exclude-gpus"] {
+		sel.Exclude = file.ExcludeGpus
+	}
+	if file.IntegratedGpus != nil {
+		sel.IntegratedModels = file.IntegratedGpus
+	}
+	if file.IncludeIntegrated != nil && !flagsSet["include-integrated"] {
+		sel.IncludeIntegrated = *file.IncludeIntegrated
+	}
+
+	if file.Backend != "" && !flagsSet["backend"] {
+		sel.Backend = file.Backend
+	}
+	for bus, backend := range file.BackendByBus {
+		if _, ok := sel.BackendByBus[bus]; !ok {
+			sel.BackendByBus[bus] = backend
+		}
+	}
+
+	return nil
+}
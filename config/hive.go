@@ -0,0 +1,71 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"miningPoolCli/utils/helpers"
+	"strings"
+)
+
+// hiveConfig is the file written by hiveos_configs/h-config.sh from the
+// flight sheet
+type hiveConfig struct {
+	PoolId     string `json:"poolId"`
+	Url        string `json:"url"`
+	Worker     string `json:"worker"`
+	UserConfig string `json:"user_config"` // CUSTOM_USER_CONFIG, extra flags
+}
+
+// HiveArgs turns the -hive-config file into command line arguments, to be
+// given before the real ones so that those take precedence
+func HiveArgs(path string) ([]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var hc hiveConfig
+	if err := json.Unmarshal(data, &hc); err != nil {
+		return nil, err
+	}
+
+	var args []string
+	if hc.PoolId != "" {
+		args = append(args, "-pool-id="+hc.PoolId)
+	}
+	// the flight sheet's pool url is often a stratum address, only an
+	// http(s) one is the pool api
+	if lower := strings.ToLower(hc.Url); strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") {
+		args = append(args, "-url="+hc.Url)
+	}
+	if hc.Worker != "" {
+		args = append(args, "-worker="+hc.Worker)
+	}
+
+	userArgs, err := helpers.SplitArgs(hc.UserConfig)
+	if err != nil {
+		return nil, err
+	}
+	return append(args, userArgs...), nil
+}
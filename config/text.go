@@ -58,6 +58,33 @@ under certain conditions; read LICENSE for details.`
 
 	Texts.GlobalHelpText = `Usage of ./miningPoolCli (Read more at ton.ninja):
 
+	./miningPoolCli [flags]            start mining
+	./miningPoolCli update [flags]     update miningPoolCli to the latest release
+	./miningPoolCli gpus [-json] [flags]
+	                                   list the GPUs that would be used and exit, 
+	                                   doesn't contact the pool
+	./miningPoolCli bench [-duration=1m] [-sequential] [-json] [-out=file] [flags]
+	                                   run the miners on a synthetic task and report 
+	                                   min/avg/max hashrate and stability per GPU, 
+	                                   doesn't contact the pool; all GPUs are 
+	                                   benchmarked at once unless -sequential, 
+	                                   -out also writes the results as JSON
+	./miningPoolCli stats [-stats-format=hiveos] [-max-age=1m] [flags]
+	                                   print the stats of the miner running with 
+	                                   -stats in the same -data-dir in the format 
+	                                   of a rig OS: hiveos, mmpos, raveos or 
+	                                   minerstat; empty if older than -max-age
+	./miningPoolCli hive-stats [flags] same as stats -stats-format=hiveos
+	./miningPoolCli status|pause|resume|restart-gpu|stop [index] [-json] [-addr=address] [flags]
+	                                   control the instance running with -serve-stat 
+	                                   in the same -data-dir (or at -addr, "host:port" 
+	                                   or "unix:/path"): status prints the GPUs, pause 
+	                                   and resume act on all GPUs or the one at index, 
+	                                   restart-gpu restarts the miner of one GPU, stop 
+	                                   needs -handle-kill; -stat-token, 
+	                                   -stat-basic-auth and -stat-tls-cert are used 
+	                                   to connect
+
 -pool-id address
 
 	Example: -pool-id=UQDu6s_r9_wmgWm5QgZuIeLep2fiSg4ijxGcJ0Sw8g4_9lvI
@@ -67,22 +94,305 @@ under certain conditions; read LICENSE for details.`
   
 	Mining pool API url. (default "https://api.ton.ninja")
 
+-worker string
+
+	Worker name sent to the pool in the "Worker-Name" header
+
+-hive-config path
+
+	HiveOS flight sheet config written by h-config.sh: 
+	{"poolId": ..., "url": ..., "worker": ..., "user_config": ...}. 
+	"user_config" (CUSTOM_USER_CONFIG) holds extra flags, e.g. 
+	-exclude-gpus="gtx 1050" -max-temp=80. "url" is used as -url 
+	only if it starts with http:// or https://, a stratum pool 
+	address is ignored. Flags given on the command line take 
+	precedence
+
 -stats bool
   
 	If this flag is set, a "stats.json" file will be created 
 	with automatically updated statistics. (Hive OS support)
 
+-stats-format list
+
+	Also write the statistics for other rig OSes, comma separated: 
+	hiveos, mmpos, raveos, minerstat. Each format is written to 
+	"stats-<format>.json" and served by -serve-stat at 
+	"/export/<format>", which is empty when the stats are more 
+	than a minute old
+
+-history-file path
+
+	Keep the hashrate history of "/history" in this file, relative 
+	to -data-dir, so it survives restarts. Saved every minute and 
+	on exit
+
+-tui bool
+
+	Show a dashboard in the terminal instead of the log: a table 
+	of the GPUs (hashrate, shares, task, sensors and state), the 
+	recent log, and keys to control the miners: up/down selects a 
+	GPU, p pauses, r resumes, x restarts and d drains it, the 
+	capital letters act on all GPUs, PgUp/PgDn scroll the log, q 
+	or Ctrl+C stops the miners and exits. Ignored without a terminal
+
 -serve-stat bool
 
 	If this flag is set, the local server serving "/stat" is started. 
 	Accepts GET and POST methods. Returns the miner's statistics in 
-	JSON format. The HTTP port is automatically selected and will be 
+	JSON format, "/metrics" serves them in the Prometheus text 
+	format. The HTTP port is automatically selected and will be 
 	printed in the terminal and written to the "` + NetSrv.HostFileName + `" file
 
+	The web dashboard at "/" shows the hashrate of each GPU, the 
+	shares, the pool's tasks and the recent log, with buttons for 
+	the control endpoints. "/tasks" and "/logs" serve the tasks and 
+	the last log lines as JSON. A browser can't send -stat-token, 
+	use -stat-basic-auth to protect the dashboard
+
+	"/stat" also has the average hashrate of each GPU and the total 
+	over the last minute, 15 minutes and hour (hashrate_1m, 
+	hashrate_15m, hashrate_1h). "/history" serves the hashrate, 
+	shares and miner restarts of each GPU over time: 
+	"?resolution=1s" for the last hour (default) or "1m" for the 
+	last day, "?since=<unix time>" and "?gpu=<index>" limit it
+
 -handle-kill bool
 
-	Allows server to process HTTP requests to "/kill" to 
+	Allows server to process HTTP POST requests to "/kill" to 
 	force the client to terminate. The flag is applied 
-	only if -serve-stat was previously specified
+	only if -serve-stat was previously specified. Endpoints that 
+	change the miner's state only accept POST requests that 
+	don't come from a web page of another site
+
+	Control endpoints of the -serve-stat server (POST): 
+	"/pause", "/resume", "/restart" and "/drain" (finish the 
+	current task, then stop) act on all GPUs, 
+	"/gpu/<index>/<action>" on one. "/reload" reads the -config 
+	and -hive-config files again and applies the GPU selection, 
+	miner settings and thermal guard; pool, server, directory and 
+	telemetry settings need a restart. If the configuration has 
+	errors they are returned and nothing changes. The "state" of 
+	each GPU is shown in "/stat"
+
+	"/events" streams Server-Sent Events as they happen: task_switch, 
+	share_found, share_accepted, share_rejected, miner_started, 
+	miner_exited, miner_restarted, hashrate (every second) and 
+	pool_error. Each event is JSON with "id", "type", "time" (unix 
+	ms), "gpu" (index as in "/stat", -1 for the whole rig) and 
+	"data". "?types=share_accepted,share_rejected" filters them, 
+	reconnecting clients get missed events with Last-Event-ID, 
+	"?last_id=0" replays the ~1000 kept events
+
+-stat-listen address
+
+	Address of the -serve-stat server instead of a random port on 
+	127.0.0.1: "host:port" (":0" picks a free port) or a unix socket, 
+	"unix:/run/miningPoolCli.sock". Can be repeated, implies 
+	-serve-stat. All addresses are written to the address file, 
+	one per line. Requests must name the server as localhost, an 
+	IP address or a host name given here, e.g. 
+	-stat-listen=rig.lan:8080, other Host headers are refused
+
+-stat-token string, -stat-basic-auth user:password
+
+	Require "Authorization: Bearer <token>" or basic auth for 
+	every request to the -serve-stat server
+
+-stat-tls-cert path, -stat-tls-key path
+
+	Serve https instead of http
+
+-stat-allow list
+
+	Comma separated IPs or CIDRs allowed to connect besides 
+	loopback, needed when -stat-listen or -claymore-api is not a 
+	loopback address. 
+	Example: -stat-allow=192.168.1.0/24
+
+-claymore-api address, -claymore-password string
+
+	Serve the Claymore / ethminer JSON-RPC API (miner_getstat1, 
+	miner_restart, miner_reboot) on TCP for monitoring tools, 
+	e.g. -claymore-api=127.0.0.1:3333. Requests must carry the 
+	password in "psw" if one is set. miner_restart restarts the 
+	miners, miner_reboot the whole client; both are refused 
+	without a password, also on loopback. Clients must be on 
+	loopback or in -stat-allow
+
+-miner-opencl path, -miner-cuda path
+
+	Use a custom OpenCL / CUDA miner executable instead of 
+	the one from the "` + MinerGetter.MinerDirectory + `" directory
+
+-miner-args string, -miner-args-opencl string, -miner-args-cuda string
+
+	Extra arguments passed to every miner / to the OpenCL or 
+	CUDA miners only. Example: -miner-args="-F 1024"
+
+-gpu-args index:string
+
+	Extra miner arguments for a single GPU, the index is the 
+	position in the GPU list printed at startup ("gpu 0: ..."), 
+	not the device id of -gpus. The arguments stay with that card 
+	(its PCI bus id) when GPUs are rediscovered. Can be repeated.
+	Example: -gpu-args="1:-F 256"
+
+-gpu-env index:KEY=VALUE
+
+	Environment variable for the miner of a single GPU. 
+	Can be repeated. Example: -gpu-env=0:GPU_MAX_ALLOC_PERCENT=100
+
+-data-dir path
+
+	Directory for runtime files: "stats.json", the server 
+	address file and the log file. Defaults to 
+	$XDG_DATA_HOME/miningPoolCli (~/.local/share/miningPoolCli) 
+	on Linux and to the working directory elsewhere
+
+-miner-dir path
+
+	Directory with the miner executables. By default "miner_blob" 
+	is searched next to the miningPoolCli executable, in the 
+	working directory and in the data directory (Linux)
+
+-log-file path
+
+	Also write the log to this file (relative to -data-dir)
+
+-miner-manifest url, -miner-manifest-key hex
+
+	Signed release manifest for downloading the miner (default 
+	"https://ton.ninja/miners/manifest.json") and the ed25519 
+	public key it must be signed with. The manifest signature is 
+	read from the same url with ".sig" appended. Downloads are 
+	resumed, checked against the manifest's sha256 and installed 
+	into "miners/<version>" in the data directory. The miner is 
+	downloaded when no miner directory is found and a key is set
+
+-miner-update bool
+
+	Install the miner release from the manifest if it differs 
+	from the installed one
+
+-miner-rollback bool
+
+	Switch back to the previously installed miner release
+
+-skip-miner-verify bool
+
+	Don't check the miner binaries against the "SHA256SUMS" file 
+	of the miner directory at startup. For development only
+
+-update-feed url, -update-key hex
+
+	Release feed used by "update" and -auto-update (default 
+	"https://ton.ninja/miningPoolCli/feed.json") and the ed25519 
+	public key the feed and the released binaries must be signed 
+	with; the feed's signature is read from "<feed url>.sig". 
+	Self-update is disabled without a key
+
+-auto-update bool, -auto-update-interval duration
+
+	Check the release feed periodically (default every 6h). 
+	A newer release is verified, replaces the executable and the 
+	client restarts itself with the same flags
+
+-gpus list, -exclude-gpus list
+
+	Comma separated GPUs to mine on / to skip. An item is a 
+	device id of the miner on any backend ("#1"), a device id of 
+	one backend ("cuda:1", "opencl:0:1" with the platform id), a 
+	PCI bus id ("01:00.0") or a part of the model name ("rx 580", 
+	"rtx 3060"). A bare number is rejected as ambiguous, write "#0" 
+	for a device id. Device ids are the ones shown by the "gpus" 
+	command, not the index used by -gpu-args, -gpu-env and the 
+	control API. 
+	Example: -exclude-gpus="opencl:0:2,gtx 1050"
+
+-include-integrated bool
+
+	Also mine on integrated GPUs and APUs (Intel and the AMD 
+	models from the integrated GPU list), skipped by default
+
+-config path
+
+	JSON config file. Flags given on the command line take 
+	precedence. Keys:
+	"gpus", "exclude_gpus"  lists, same as the flags
+	"integrated_gpus"       model name parts of integrated GPUs, 
+	                        replaces the built-in list
+	"include_integrated"    same as the flag
+	"backend"               same as the flag
+	"gpu_backend"           object, PCI bus id -> backend
+	"devices"               list of devices to mine on instead of 
+	                        parsing the miner output: {"backend": 
+	                        "opencl", "platform_id": 0, "device_id": 1, 
+	                        "label": "RX 580", "bus_id": "01:00.0"}
+
+-backend auto|cuda|opencl, -gpu-backend list
+
+	NVIDIA cards are seen by both the CUDA and the OpenCL miner, 
+	duplicates are found by PCI bus id (read from sysfs on Linux). 
+	-backend picks the miner for all such cards (default auto, 
+	which is CUDA), -gpu-backend per card. 
+	Example: -gpu-backend="01:00.0=opencl,02:00.0=cuda"
+
+-sysfs-root path
+
+	Where sysfs is mounted (default "/sys")
+
+-discover-only bool
+
+	Print how the miner output was parsed (why each line was 
+	accepted or rejected) and the devices that would be used, 
+	then exit. Doesn't need -pool-id
+
+-rediscover-interval duration
+
+	Discover the GPUs again periodically (e.g. 5m, disabled by 
+	default): miners are started on new GPUs, stopped on the ones 
+	that disappeared and restarted when a GPU's device id changed. 
+	If a miner can't be run or no GPU is found the running miners 
+	are kept. With -serve-stat a POST to "/rediscover" does it on 
+	demand
+
+-nvidia-smi path, -rocm-smi path, -telemetry-interval duration
+
+	GPU temperature, fan speed and power draw are read from 
+	nvidia-smi, rocm-smi and the hwmon sensors in sysfs every 
+	10s by default. Set a path to "" to skip that tool, the 
+	interval to 0 to disable. The readings are shown in "/stat", 
+	"/metrics" and "stats.json". GPUs are matched by PCI bus id, 
+	NVIDIA GPUs without one (e.g. on Windows) by the nvidia-smi 
+	index unless CUDA_DEVICE_ORDER is changed from PCI_BUS_ID or 
+	CUDA_VISIBLE_DEVICES is set
+
+-max-temp °C, -temp-hysteresis °C, -max-power W
+
+	Thermal guard, needs telemetry. A GPU's miner is paused when 
+	it reaches -max-temp or -max-power and resumed when it is 
+	-temp-hysteresis degrees cooler (default 10) and below 90% 
+	of -max-power. GPUs whose telemetry can't be matched are not 
+	mined on when it's set. Disabled by default
+
+-gpu-max-temp index:°C
+
+	-max-temp for a single GPU, it must have telemetry. Can be 
+	repeated. 
+	Example: -gpu-max-temp=1:75
+
+-throttle-boost int
+
+	Restart a hot GPU's miner with this boost factor (-F) instead 
+	of pausing it. It is still paused if it keeps heating up to 
+	-max-temp plus -temp-hysteresis
+
+-thermal-alert path
+
+	Command run when the guard trips or clears, with the variables 
+	MININGPOOLCLI_GPU (index), MININGPOOLCLI_GPU_MODEL, 
+	MININGPOOLCLI_GPU_BUS_ID, MININGPOOLCLI_TEMP, MININGPOOLCLI_POWER 
+	and MININGPOOLCLI_THERMAL (paused, throttled or ok)
 `
 }
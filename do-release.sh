@@ -71,6 +71,21 @@ case $PLATFORM in
     cp -r miner_blob $FOLDER
     sed -i -e "s/CUSTOM_VERSION=/CUSTOM_VERSION=${BUILD_VERSION}/g" $FOLDER/h-manifest.conf
     tar -zcvf "${CLI_NAME}-${BUILD_VERSION}-linux.tar.gz" $FOLDER
+
+    # a package per rig OS, with its integration scripts instead of HiveOS's
+    for RIG_OS in mmpos raveos minerstat; do
+        rm -rf $FOLDER
+        mkdir $FOLDER
+        touch "${FOLDER}/VERSION_${BUILD_VERSION}_${GOOS}_${GOARCH}"
+        cp LICENSE README.md $CLI_NAME $FOLDER
+        cp -r miner_blob $FOLDER
+        cp ${RIG_OS}_configs/* $FOLDER
+        case $RIG_OS in
+          mmpos) sed -i -e "s/EXTERNAL_VERSION=/EXTERNAL_VERSION=${BUILD_VERSION}/g" $FOLDER/mmp-external.conf ;;
+          raveos) sed -i -e "s/\"version\": \"\"/\"version\": \"${BUILD_VERSION}\"/g" $FOLDER/manifest.json ;;
+        esac
+        tar -zcvf "${CLI_NAME}-${BUILD_VERSION}-${RIG_OS}.tar.gz" $FOLDER
+    done
     ;;
   windows) 
     cp "${CLI_NAME}.exe" $FOLDER
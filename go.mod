@@ -6,6 +6,7 @@ require (
 	github.com/go-errors/errors v1.5.1
 	github.com/valyala/fasthttp v1.51.0
 	github.com/xssnick/tonutils-go v1.8.9
+	golang.org/x/term v0.10.0
 )
 
 require (
@@ -13,4 +14,5 @@ require (
 	github.com/klauspost/compress v1.17.5 // indirect
 	github.com/sigurn/crc16 v0.0.0-20211026045750-20ab5afb07e3 // indirect
 	github.com/valyala/bytebufferpool v1.0.0 // indirect
+	golang.org/x/sys v0.13.0 // indirect
 )
@@ -6,4 +6,14 @@ CUSTOM_DIR=$(dirname "$BASH_SOURCE")
 
 [[ -z $CUSTOM_TEMPLATE ]] && echo -e "CUSTOM_TEMPLATE is empty" && return 1
 
-echo "{\"poolId\": \"$CUSTOM_TEMPLATE\"}" > $CUSTOM_CONFIG_FILENAME
\ No newline at end of file
+# the flight sheet's pool url is often a stratum address, only an http(s)
+# one is the pool api
+url=
+[[ $CUSTOM_URL =~ ^https?:// ]] && url=$CUSTOM_URL
+
+jq -n \
+	--arg poolId "$CUSTOM_TEMPLATE" \
+	--arg url "$url" \
+	--arg worker "$WORKER_NAME" \
+	--arg user_config "$CUSTOM_USER_CONFIG" \
+	'{$poolId, $url, $worker, $user_config}' > $CUSTOM_CONFIG_FILENAME
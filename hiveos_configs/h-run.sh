@@ -13,9 +13,5 @@ mkdir -p $CUSTOM_LOG_BASEDIR
 touch $CUSTOM_LOG_BASENAME.log
 
 echo "PATH CONFIG: ${CUSTOM_CONFIG_FILENAME}"
-POOL_ID=`jq .poolId ${CUSTOM_CONFIG_FILENAME}`
-POOL_ID=${POOL_ID:1:-1}
 
-echo "POOL_ID: ${POOL_ID}"
-
-./miningPoolCli -stats -pool-id=$POOL_ID | tee --append $CUSTOM_LOG_BASENAME.log
\ No newline at end of file
+./miningPoolCli -stats -data-dir=$CUSTOM_DIR -hive-config=$CUSTOM_CONFIG_FILENAME | tee --append $CUSTOM_LOG_BASENAME.log
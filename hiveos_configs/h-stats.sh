@@ -2,37 +2,25 @@
 DIRNAME=$(dirname "$BASH_SOURCE")
 . $DIRNAME/h-manifest.conf
 
-STATS_FILE=/hive/miners/custom/${CUSTOM_NAME}/stats.json
+stats=$($DIRNAME/miningPoolCli hive-stats -data-dir=$DIRNAME)
+khs=$(jq .khs <<< "$stats")
 
-temp=$(jq '.temp' <<< $gpu_stats)
-fan=$(jq '.fan' <<< $gpu_stats)
-[[ $cpu_indexes_array != '[]' ]] &&
-    temp=$(jq -c "del(.$cpu_indexes_array)" <<< $temp) &&
-    fan=$(jq -c "del(.$cpu_indexes_array)" <<< $fan)
+# temperature and fan the miner has no telemetry for are taken from HiveOS,
+# matched by bus number, by position if the miner doesn't know the bus
+hive=$(jq -c '{temp, fan, busids}' <<< "${gpu_stats:-null}" 2>/dev/null)
+[[ -n $hive && $cpu_indexes_array != '[]' && -n $cpu_indexes_array ]] &&
+    hive=$(jq -c ".temp |= del(.$cpu_indexes_array) | .fan |= del(.$cpu_indexes_array) | .busids |= del(.$cpu_indexes_array)" <<< "$hive")
 
-busids=$(jq '.busids' <<< $gpu_stats)
-
-BUS_NUMBERS=()
-for i in "${!busids[@]}"; do 
-  BUS_NUMER_HEX=$(echo ${busids[$i]:0:2} | tr "a-z" "A-Z")
-  BUS_NUM=$(echo "obase=10; ibase=16; $BUS_NUMER_HEX" | bc)
-  BUS_NUMBERS+=($BUS_NUM)
-done
-
-if [[ ! -f $STATS_FILE ]]; then
-    echo "$STATS_FILE not found"
-else
-    khs=$(jq .khs $STATS_FILE)
-    hs=$(jq .hs $STATS_FILE)
-    uptime=$(jq .uptime $STATS_FILE)
-    bus_numbers=$(echo "${BUS_NUMBERS[@]}" | jq -s '.')
-
-    stats=$(jq -n \
-    --argjson hs "$hs" \
-    --arg uptime "$uptime" \
-    --arg ver "$CUSTOM_VERSION" \
-    --argjson temp "$temp" \
-    --argjson fan "$fan" \
-    --argjson bus_numbers "$bus_numbers" \
-    '{"hs": $hs, "hs_units": "mhs", "algo": "sha256", "uptime": $uptime, "ver": $ver, "temp": $temp, "fan": $fan, "bus_numbers": $bus_numbers}')
-fi
+stats=$(jq -c --argjson hive "${hive:-null}" '
+    def hexnum: ascii_downcase | explode | reduce .[] as $c (0; . * 16 + if $c >= 97 then $c - 87 else $c - 48 end);
+    if $hive == null then . else
+        ($hive.busids // [] | map(if type == "string" then .[0:2] | hexnum else null end)) as $buses
+        | (.bus_numbers // []) as $bus
+        | def pick($key; $i):
+            if .[$key][$i] != 0 then .[$key][$i]
+            else (if $bus[$i] != null then ($buses | index($bus[$i])) else $i end) as $j
+                | if $j != null then ($hive[$key][$j] // 0) else 0 end
+            end;
+        .temp = [range(0; .temp | length) as $i | pick("temp"; $i)]
+        | .fan = [range(0; .fan | length) as $i | pick("fan"; $i)]
+    end' <<< "$stats")
@@ -2,46 +2,69 @@ package main
 
 import (
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"math/rand"
 	"miningPoolCli/config"
 	"miningPoolCli/utils/api"
+	"miningPoolCli/utils/events"
+	"miningPoolCli/utils/export"
 	"miningPoolCli/utils/gpuwrk"
 	"miningPoolCli/utils/helpers"
+	"miningPoolCli/utils/history"
 	"miningPoolCli/utils/initp"
 	"miningPoolCli/utils/logreport"
 	"miningPoolCli/utils/mlog"
+	"miningPoolCli/utils/selfupdate"
 	"miningPoolCli/utils/server"
-	"os/exec"
+	"miningPoolCli/utils/tui"
+	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/xssnick/tonutils-go/address"
 	"github.com/xssnick/tonutils-go/tvm/cell"
 )
 
-var gpuGoroutines []gpuwrk.GpuGoroutine
+var gpuGoroutines []*gpuwrk.GpuGoroutine
 var globalTasks []api.Task
 
-func startTask(i int, task api.Task) {
-	// gpuGoroutines[i].startTimestamp = time.Now().Unix()
+var rediscoverMu sync.Mutex
+
+// gpuIndex is the position of a worker as in /stat, -1 if it's not in the
+// list anymore
+func gpuIndex(g *gpuwrk.GpuGoroutine) int {
+	gpuwrk.WorkersMu.RLock()
+	defer gpuwrk.WorkersMu.RUnlock()
+	for i, w := range gpuGoroutines {
+		if w == g {
+			return i
+		}
+	}
+	return -1
+}
+
+func startTask(g *gpuwrk.GpuGoroutine, task api.Task) {
+	// g.startTimestamp = time.Now().Unix()
+	gpuwrk.WorkersMu.RLock()
+	runnable := g.Runnable()
+	gpuwrk.WorkersMu.RUnlock()
+	if !runnable {
+		return
+	}
 
 	if task.Expire < time.Now().Unix() {
-		if gpuGoroutines[i].KeepAlive {
-			enableTask(i)
+		if g.KeepAlive() {
+			enableTask(g)
 
 		}
 		return
 	}
-	minerArgs := []string{
-		// "-vv",
-		// "-V",
-		// "-B",
-		"-g" + strconv.Itoa(gpuGoroutines[i].GpuData.GpuId),
-		"-p" + strconv.Itoa(gpuGoroutines[i].GpuData.PlatformId),
-		"-F" + strconv.Itoa(config.StaticBeforeMinerSettings.BoostFactor),
-		"-t" + strconv.Itoa(config.StaticBeforeMinerSettings.TimeoutT),
+	cmd := gpuwrk.MinerCommand(
+		g.GpuData,
+		g.BoostFactor(),
 		// "-e" + strconv.FormatInt(task.Expire, 10),
 		config.StaticBeforeMinerSettings.PoolAddress,
 		helpers.ConvertHexData(task.Seed),
@@ -49,33 +72,67 @@ func startTask(i int, task api.Task) {
 		config.StaticBeforeMinerSettings.Iterations,
 		// task.Giver,
 		// pathToBoc,
-	}
-	cmd := exec.Command(gpuGoroutines[i].GpuData.StartPath, minerArgs...)
-
-	gpuGoroutines[i].ProcStderr.Reset()
-	cmd.Stderr = &gpuGoroutines[i].ProcStderr
+	)
 
 	unblockFunc := make(chan struct{}, 1)
 
 	var killedByNotActual bool
 	var done bool
 
-	if err := cmd.Start(); err != nil {
+	// checks again under the worker's lock, another task may have been
+	// started since the gpu was found idle
+	stderr, err := g.StartMiner(cmd, task.Id)
+	if err != nil {
 		mlog.LogFatal("failed to start miner cmd; err: " + err.Error() + "; args: " + strings.Join(cmd.Args, " "))
 	}
+	if stderr == nil {
+		return
+	}
 
-	gpuGoroutines[i].PPid = cmd.Process.Pid
-	gpuGoroutines[i].KeepAlive = true
+	events.Publish(events.MinerStarted, gpuIndex(g), map[string]interface{}{
+		"task_id": task.Id,
+		"pid":     cmd.Process.Pid,
+	})
 
 	go func() {
 		cmd.Wait()
 		done = true
+		stopped := g.MinerExited()
 
-		out := gpuGoroutines[i].ProcStderr.String()
+		out := stderr.String()
 		lines := strings.Split(out, "\n")
+		found := len(lines) > 3 && strings.Contains(lines[len(lines)-3], "FOUND!")
+
+		reason := "exited"
+		switch {
+		case killedByNotActual:
+			reason = "task_expired"
+		case stopped:
+			reason = "stopped"
+		case found:
+			reason = "found"
+		case !cmd.ProcessState.Success():
+			reason = "crashed"
+		}
+		index := gpuIndex(g)
+		events.Publish(events.MinerExited, index, map[string]interface{}{
+			"task_id":   task.Id,
+			"reason":    reason,
+			"exit_code": cmd.ProcessState.ExitCode(),
+		})
+		if (reason == "stopped" || reason == "crashed") && g.KeepAlive() && g.Runnable() {
+			history.Restart(gpuwrk.Key(g.GpuData))
+			events.Publish(events.MinerRestarted, index, map[string]interface{}{
+				"reason": reason,
+			})
+		}
+
 		if len(lines) > 3 {
-			if strings.Contains(lines[len(lines)-3], "FOUND!") {
+			if found {
 				if !killedByNotActual {
+					events.Publish(events.ShareFound, index, map[string]interface{}{
+						"task_id": task.Id,
+					})
 					go func() {
 						lineWithProof := lines[len(lines)-2]
 						if len(lineWithProof) < 246 {
@@ -103,10 +160,28 @@ func startTask(i int, task api.Task) {
 						bocServerResp, err := api.SendHexBocToServer(hex.EncodeToString(extCell.ToBOC()), task.Seed, strconv.Itoa(task.Id))
 						if err == nil {
 							if bocServerResp.Data == "Found" && bocServerResp.Status == "ok" {
-								logreport.ShareFound(gpuGoroutines[i].GpuData.Model, gpuGoroutines[i].GpuData.GpuId, task.Id)
+								logreport.ShareFound(g.GpuData.Model, g.GpuData.GpuId, task.Id)
+								g.CountShare(true)
+								history.Share(gpuwrk.Key(g.GpuData), true)
+								events.Publish(events.ShareAccepted, gpuIndex(g), map[string]interface{}{
+									"task_id": task.Id,
+								})
 							} else {
-								logreport.ShareServerError(task, bocServerResp, gpuGoroutines[i].GpuData.GpuId)
+								logreport.ShareServerError(task, bocServerResp, g.GpuData.GpuId)
+								g.CountShare(false)
+								history.Share(gpuwrk.Key(g.GpuData), false)
+								events.Publish(events.ShareRejected, gpuIndex(g), map[string]interface{}{
+									"task_id": task.Id,
+									"status":  bocServerResp.Status,
+									"code":    bocServerResp.Code,
+									"data":    bocServerResp.Data,
+								})
 							}
+						} else {
+							events.Publish(events.PoolError, gpuIndex(g), map[string]interface{}{
+								"request": "boc",
+								"error":   err.Error(),
+							})
 						}
 					}()
 				}
@@ -117,8 +192,8 @@ func startTask(i int, task api.Task) {
 			))
 		}
 
-		if gpuGoroutines[i].KeepAlive {
-			enableTask(i)
+		if g.KeepAlive() {
+			enableTask(g)
 		}
 
 		unblockFunc <- struct{}{}
@@ -160,10 +235,30 @@ func checkTaskAlreadyFound(checkId int) *api.Task {
 	return nil
 }
 
+// taskIds lists the ids of the tasks, to tell when the pool switched them
+func taskIds(tasks []api.Task) []int {
+	ids := make([]int, 0, len(tasks))
+	for _, task := range tasks {
+		ids = append(ids, task.Id)
+	}
+	return ids
+}
+
 func syncTasks(firstSync *chan struct{}) {
 	var firstSyncIsOk bool
 	for {
-		if v, err := api.GetTasks(); err == nil && len(v.Tasks) > 0 {
+		v, err := api.GetTasks()
+		if err != nil {
+			events.Publish(events.PoolError, -1, map[string]interface{}{
+				"request": "get",
+				"error":   err.Error(),
+			})
+		} else if len(v.Tasks) > 0 {
+			if ids := taskIds(v.Tasks); fmt.Sprint(ids) != fmt.Sprint(taskIds(globalTasks)) {
+				events.Publish(events.TaskSwitch, -1, map[string]interface{}{
+					"tasks": ids,
+				})
+			}
 			globalTasks = v.Tasks
 			if !firstSyncIsOk {
 				*firstSync <- struct{}{}
@@ -175,37 +270,319 @@ func syncTasks(firstSync *chan struct{}) {
 	}
 }
 
-func enableTask(gpuGoIndex int) {
+func enableTask(g *gpuwrk.GpuGoroutine) {
 	if tLen := len(globalTasks); tLen > 0 {
-		go startTask(gpuGoIndex, globalTasks[rand.Intn(len(globalTasks))])
+		go startTask(g, globalTasks[rand.Intn(len(globalTasks))])
 	} else {
 		mlog.LogError("can't start task, because the len of globalTasks <= 0")
 	}
 }
 
+// rediscover runs GPU discovery again: workers are started for new gpus,
+// stopped for vanished ones and restarted when a gpu's ids changed (e.g.
+// after a driver reset). Healthy workers are left alone, all of them if
+// discovery fails.
+func rediscover() (gpuwrk.RediscoverResult, error) {
+	rediscoverMu.Lock()
+	defer rediscoverMu.Unlock()
+
+	gpus, err := initp.RediscoverGpus()
+	if err != nil {
+		mlog.LogInfo("warning: GPU rediscovery failed, keeping the current GPUs: " + err.Error())
+		return gpuwrk.RediscoverResult{}, err
+	}
+
+	gpuwrk.WorkersMu.Lock()
+	workers, result := gpuwrk.MergeWorkers(gpuGoroutines, gpus)
+	gpuGoroutines = workers
+	gpuwrk.WorkersMu.Unlock()
+
+	for _, g := range result.Started {
+		enableTask(g)
+	}
+	for _, g := range result.Restarted {
+		g.StopMiner()
+	}
+	for _, g := range result.Retired {
+		g.StopMiner()
+	}
+
+	result.Log()
+	return result, nil
+}
+
+// thermalGuard pauses, throttles and resumes the miners after each
+// telemetry update. Without limits nothing trips, and gpus paused before a
+// reload removed them are resumed.
+func thermalGuard() {
+	gpuwrk.WorkersMu.Lock()
+	changes := gpuwrk.CheckThermal(gpuGoroutines)
+	gpuwrk.WorkersMu.Unlock()
+
+	for _, c := range changes {
+		c.Alert()
+		if c.From == gpuwrk.ThermalPaused {
+			enableTask(c.Gpu)
+		} else {
+			// restarted with the new boost factor, or not at all if paused
+			c.Gpu.StopMiner()
+		}
+	}
+}
+
+// controlGpus applies a control api action (pause, resume, restart, drain)
+// to the worker at index, or to all of them if index is -1
+func controlGpus(action string, index int) error {
+	switch action {
+	case "pause", "resume", "restart", "drain":
+	default:
+		return errors.New("unknown action \"" + action + "\"")
+	}
+
+	gpuwrk.WorkersMu.Lock()
+	targets := gpuGoroutines
+	if index >= 0 {
+		if index >= len(gpuGoroutines) {
+			gpuwrk.WorkersMu.Unlock()
+			return errors.New("no gpu " + strconv.Itoa(index))
+		}
+		targets = gpuGoroutines[index : index+1]
+	}
+
+	var stop, start []*gpuwrk.GpuGoroutine
+	for _, g := range targets {
+		switch action {
+		case "pause":
+			g.Paused = true
+			stop = append(stop, g)
+		case "resume":
+			g.Paused, g.Draining = false, false
+			if !g.Running() && g.Runnable() {
+				start = append(start, g)
+			}
+		case "restart":
+			stop = append(stop, g)
+		case "drain":
+			// the running miner finishes its task, no new one is started
+			g.Draining = true
+		}
+	}
+	gpuwrk.WorkersMu.Unlock()
+
+	for _, g := range stop {
+		g.StopMiner()
+	}
+	for _, g := range start {
+		enableTask(g)
+	}
+
+	target := "all GPUs"
+	if index >= 0 {
+		target = "GPU " + strconv.Itoa(index)
+	}
+	mlog.LogInfo("Control: " + action + " " + target)
+	return nil
+}
+
+// reload reads the config files again and applies them by rediscovering
+// the gpus
+func reload() error {
+	rediscoverMu.Lock()
+	gpuwrk.WorkersMu.RLock()
+	gpus := make([]gpuwrk.GPUstruct, len(gpuGoroutines))
+	for i, g := range gpuGoroutines {
+		gpus[i] = g.GpuData
+	}
+	gpuwrk.WorkersMu.RUnlock()
+	err := initp.Reload(gpus)
+	rediscoverMu.Unlock()
+
+	if err != nil {
+		return err
+	}
+	mlog.LogOk("Configuration reloaded")
+	rediscover()
+	return nil
+}
+
+// reboot stops the miners and runs the client again with the same flags
+func reboot() {
+	exe, err := os.Executable()
+	if err != nil {
+		mlog.LogError("Reboot failed: " + err.Error())
+		return
+	}
+
+	gpuwrk.KillAll(&gpuGoroutines)
+	mlog.RunAtExit()
+	mlog.LogOk("Rebooting miningPoolCli")
+	if err := selfupdate.Reexec(exe); err != nil {
+		mlog.LogFatal("Reboot failed: " + err.Error())
+	}
+}
+
+// recordHistory adds the current hashrates to the history
+func recordHistory() {
+	gpuwrk.WorkersMu.RLock()
+	defer gpuwrk.WorkersMu.RUnlock()
+	for _, g := range gpuGoroutines {
+		history.Hashrate(gpuwrk.Key(g.GpuData), g.CurrentHashrate)
+	}
+}
+
+// persistHistory loads the -history-file and saves it every minute and
+// before exiting
+func persistHistory() {
+	path := config.DataPath(config.HistoryFile)
+	if err := history.Load(path); err == nil {
+		mlog.LogInfo("Hashrate history loaded from " + path)
+	} else if !os.IsNotExist(err) {
+		mlog.LogError("Can't load the hashrate history: " + err.Error())
+	}
+
+	save := func() {
+		if err := history.Save(path); err != nil {
+			mlog.LogError("Can't save the hashrate history: " + err.Error())
+		}
+	}
+	mlog.AtExit(save)
+
+	go func() {
+		for {
+			time.Sleep(time.Minute)
+			save()
+		}
+	}()
+}
+
+func autoUpdate() {
+	updater, err := selfupdate.New()
+	if err != nil {
+		mlog.LogError("Auto-update disabled: " + err.Error())
+		return
+	}
+
+	for {
+		time.Sleep(config.UpdateSettings.Interval)
+
+		version, err := updater.Update()
+		if err != nil {
+			mlog.LogError("Auto-update failed: " + err.Error())
+			continue
+		}
+		if version == "" {
+			continue
+		}
+
+		gpuwrk.KillAll(&gpuGoroutines)
+		mlog.RunAtExit()
+		mlog.LogOk("Restarting with miningPoolCli " + version)
+		if err := selfupdate.Reexec(updater.Executable); err != nil {
+			mlog.LogFatal("Restart after update failed: " + err.Error())
+		}
+	}
+}
+
 func main() {
 	rand.Seed(time.Now().Unix())
+	selfupdate.Cleanup()
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "update":
+			initp.ParseFlags(os.Args[2:])
+			selfupdate.RunCommand()
+			return
+		case "gpus":
+			initp.GpusCommand(os.Args[2:])
+			return
+		case "bench":
+			initp.BenchCommand(os.Args[2:])
+			return
+		case "stats":
+			initp.StatsCommand(os.Args[2:], "hiveos")
+			return
+		case "hive-stats":
+			initp.StatsCommand(os.Args[2:], "hiveos")
+			return
+		case "status", "pause", "resume", "restart-gpu", "stop":
+			initp.CtlCommand(os.Args[1], os.Args[2:])
+			return
+		}
+	}
+
+	// saves the history and restores the -tui terminal on Ctrl+C / SIGTERM
+	mlog.ExitOnSignal()
+
 	gpus := initp.InitProgram()
 
-	gpuGoroutines = make([]gpuwrk.GpuGoroutine, len(gpus))
+	if config.HistoryFile != "" {
+		persistHistory()
+	}
 
 	firstSync := make(chan struct{})
 	go syncTasks(&firstSync)
 	<-firstSync
 
-	for gpuGoIndex := range gpuGoroutines {
-		gpuGoroutines[gpuGoIndex].GpuData = gpus[gpuGoIndex]
-		enableTask(gpuGoIndex)
+	for _, gpu := range gpus {
+		g := &gpuwrk.GpuGoroutine{GpuData: gpu}
+		gpuGoroutines = append(gpuGoroutines, g)
+		enableTask(g)
+	}
+
+	if config.TUI {
+		if err := tui.Start(&gpuGoroutines, controlGpus); err != nil {
+			mlog.LogError(err.Error())
+			config.TUI = false
+		}
+	}
+
+	control := server.Control{
+		Rediscover: rediscover,
+		Action:     controlGpus,
+		Reload:     reload,
+		Reboot:     reboot,
+		Tasks:      func() []api.Task { return globalTasks },
 	}
 
 	if !config.NetSrv.RunThis && config.NetSrv.HandleKill {
 		mlog.LogInfo("Unable to apply -handle-kill because flag -serve-stat is not specified")
 	} else if config.NetSrv.RunThis {
-		go server.Entrypoint(&gpuGoroutines)
+		go server.Entrypoint(&gpuGoroutines, control)
+	}
+
+	if config.NetSrv.ClaymoreAddr != "" {
+		go server.ClaymoreAPI(config.NetSrv.ClaymoreAddr, &gpuGoroutines, control)
+	}
+
+	if config.RediscoverInterval > 0 {
+		go func() {
+			for {
+				time.Sleep(config.RediscoverInterval)
+				rediscover()
+			}
+		}()
+	}
+
+	if config.Telemetry.Interval > 0 {
+		go gpuwrk.PollTelemetry(&gpuGoroutines, thermalGuard)
+	}
+
+	if config.UpdateSettings.Auto {
+		go autoUpdate()
 	}
 
 	for {
 		time.Sleep(1 * time.Second)
-		gpuwrk.CalcHashrate(&gpuGoroutines)
+		if stats, ok := gpuwrk.CalcHashrate(&gpuGoroutines); ok {
+			recordHistory()
+			events.Publish(events.Hashrate, -1, map[string]interface{}{
+				"total": stats.Khs,
+				"hs":    stats.Hs,
+			})
+			if config.UpdateStatsFile {
+				export.WriteFiles(stats)
+			}
+		}
 	}
 }
@@ -0,0 +1,6 @@
+#!/usr/bin/env bash
+# Prints the stats of the running miner for the minerstat client
+
+DIRNAME=$(dirname "$BASH_SOURCE")
+
+$DIRNAME/miningPoolCli stats -stats-format=minerstat -data-dir=$DIRNAME
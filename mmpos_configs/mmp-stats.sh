@@ -0,0 +1,6 @@
+#!/usr/bin/env bash
+# Prints the stats of the running miner for the mmpOS agent
+
+DIRNAME=$(dirname "$BASH_SOURCE")
+
+$DIRNAME/miningPoolCli stats -stats-format=mmpos -data-dir=$DIRNAME
@@ -0,0 +1,7 @@
+#!/usr/bin/env bash
+# Starts the miner, the RaveOS miner arguments are passed through,
+# e.g. -pool-id=<your pool id>
+
+DIRNAME=$(dirname "$BASH_SOURCE")
+
+exec $DIRNAME/miningPoolCli -stats -data-dir=$DIRNAME "$@"
@@ -0,0 +1,6 @@
+#!/usr/bin/env bash
+# Prints the stats of the running miner in the RaveOS miner API format
+
+DIRNAME=$(dirname "$BASH_SOURCE")
+
+$DIRNAME/miningPoolCli stats -stats-format=raveos -data-dir=$DIRNAME
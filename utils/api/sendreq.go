@@ -59,6 +59,9 @@ func sendPostJsonReqAttempt(jsonData []byte, serverUrl string) ([]byte, error) {
 	httpReq.Header.SetMethod(fasthttp.MethodPost)
 	httpReq.Header.SetContentType("application/json; charset=UTF-8")
 	httpReq.Header.Set("Build-Version", config.BuildVersion)
+	if config.ServerSettings.Worker != "" {
+		httpReq.Header.Set("Worker-Name", config.ServerSettings.Worker)
+	}
 	httpReq.SetBody(jsonData)
 
 	httpResp := fasthttp.AcquireResponse()
@@ -86,6 +89,9 @@ func GetReqAttempt(serverUrl string) ([]byte, error) {
 	httpReq.Header.SetMethod(fasthttp.MethodGet)
 	httpReq.Header.SetContentType("application/json; charset=UTF-8")
 	httpReq.Header.Set("Build-Version", config.BuildVersion)
+	if config.ServerSettings.Worker != "" {
+		httpReq.Header.Set("Worker-Name", config.ServerSettings.Worker)
+	}
 
 	httpResp := fasthttp.AcquireResponse()
 	defer fasthttp.ReleaseResponse(httpResp)
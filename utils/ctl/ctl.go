@@ -0,0 +1,136 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package ctl is the client of the control api of a running instance
+package ctl
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"miningPoolCli/config"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const unixPrefix = "unix:"
+
+type Client struct {
+	Addr string // "host:port" or "unix:/path", as in the address file
+	http *http.Client
+	base string
+}
+
+// Addr finds the address of the local instance: the given one, otherwise
+// the first line of the address file in the data directory
+func Addr(addr string) (string, error) {
+	if addr != "" {
+		return addr, nil
+	}
+
+	hostFile := config.DataPath(config.NetSrv.HostFileName)
+	data, err := ioutil.ReadFile(hostFile)
+	if err != nil {
+		return "", errors.New("can't find the running instance (" + err.Error() + "); " +
+			"is it running with -serve-stat and the same -data-dir? Or give -addr")
+	}
+	lines := strings.Fields(string(data))
+	if len(lines) == 0 {
+		return "", errors.New(hostFile + " is empty")
+	}
+	return lines[0], nil
+}
+
+// New creates the client for addr with the -stat-token / -stat-basic-auth
+// and -stat-tls-cert settings
+func New(addr string) (*Client, error) {
+	transport := &http.Transport{}
+	scheme := "http"
+
+	if config.NetSrv.TLSCert != "" {
+		scheme = "https"
+		pem, err := ioutil.ReadFile(config.NetSrv.TLSCert)
+		if err != nil {
+			return nil, err
+		}
+		roots := x509.NewCertPool()
+		roots.AppendCertsFromPEM(pem)
+		transport.TLSClientConfig = &tls.Config{RootCAs: roots}
+	}
+
+	host := addr
+	if strings.HasPrefix(addr, unixPrefix) {
+		path := strings.TrimPrefix(addr, unixPrefix)
+		host = "localhost"
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		}
+	}
+
+	return &Client{
+		Addr: addr,
+		http: &http.Client{Transport: transport, Timeout: 30 * time.Second},
+		base: scheme + "://" + host,
+	}, nil
+}
+
+// Call sends a request to the api and returns the response body. Error
+// responses are returned as errors.
+func (c *Client) Call(method, path string) ([]byte, error) {
+	req, err := http.NewRequest(method, c.base+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token := config.NetSrv.Token; token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else if basic := config.NetSrv.BasicAuth; basic != "" {
+		sep := strings.Index(basic, ":")
+		req.SetBasicAuth(basic[:sep], basic[sep+1:])
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, errors.New("can't reach the instance at " + c.Addr + ": " + err.Error())
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		msg := strings.TrimSpace(string(body))
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Error != "" {
+			msg = apiErr.Error
+		}
+		return body, errors.New(path + ": " + resp.Status + ": " + msg)
+	}
+	return body, nil
+}
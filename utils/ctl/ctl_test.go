@@ -0,0 +1,131 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package ctl
+
+import (
+	"io/ioutil"
+	"miningPoolCli/config"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAddr(t *testing.T) {
+	config.Configure()
+	config.Paths.DataDir = t.TempDir()
+	hostFile := filepath.Join(config.Paths.DataDir, config.NetSrv.HostFileName)
+
+	if _, err := Addr(""); err == nil || !strings.Contains(err.Error(), "-addr") {
+		t.Errorf("without an address file: %v", err)
+	}
+	if addr, err := Addr("127.0.0.1:1"); addr != "127.0.0.1:1" || err != nil {
+		t.Errorf("Addr(127.0.0.1:1) = %q, %v", addr, err)
+	}
+
+	ioutil.WriteFile(hostFile, []byte("\n"), 0644)
+	if _, err := Addr(""); err == nil {
+		t.Error("empty address file accepted")
+	}
+
+	ioutil.WriteFile(hostFile, []byte("unix:/run/mpc.sock\n127.0.0.1:8080\n"), 0644)
+	if addr, err := Addr(""); addr != "unix:/run/mpc.sock" || err != nil {
+		t.Errorf("Addr() = %q, %v", addr, err)
+	}
+}
+
+// echoAuth answers with the method, path and Authorization header of the
+// request, or an api error for /fail
+func echoAuth(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/fail":
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"status": false, "error": "no gpu 9"}`))
+	case "/plain":
+		http.Error(w, "gone", http.StatusGone)
+	default:
+		w.Write([]byte(r.Method + " " + r.URL.Path + " " + r.Header.Get("Authorization")))
+	}
+}
+
+func TestCall(t *testing.T) {
+	config.Configure()
+	srv := httptest.NewServer(http.HandlerFunc(echoAuth))
+	defer srv.Close()
+
+	tests := []struct {
+		name, token, basic, method, path string
+		want, wantErr                    string
+	}{
+		{"no auth", "", "", "GET", "/stat", "GET /stat ", ""},
+		{"token", "secret", "user:pw", "POST", "/pause", "POST /pause Bearer secret", ""},
+		{"basic auth", "", "user:p:w", "GET", "/stat", "GET /stat Basic dXNlcjpwOnc=", ""},
+		{"api error", "", "", "POST", "/fail", "", "/fail: 400 Bad Request: no gpu 9"},
+		{"plain error", "", "", "GET", "/plain", "", "/plain: 410 Gone: gone"},
+	}
+
+	for _, tt := range tests {
+		config.NetSrv.Token, config.NetSrv.BasicAuth = tt.token, tt.basic
+		client, err := New(strings.TrimPrefix(srv.URL, "http://"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body, err := client.Call(tt.method, tt.path)
+		if tt.wantErr != "" {
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("%s: err = %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || string(body) != tt.want {
+			t.Errorf("%s: %q, %v, want %q", tt.name, body, err, tt.want)
+		}
+	}
+}
+
+func TestCallUnixSocket(t *testing.T) {
+	config.Configure()
+	path := filepath.Join(t.TempDir(), "mpc.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skip("no unix sockets: " + err.Error())
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(echoAuth)}
+	go srv.Serve(listener)
+	defer srv.Close()
+
+	client, err := New(unixPrefix + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, err := client.Call("GET", "/stat"); err != nil || string(body) != "GET /stat " {
+		t.Errorf("Call = %q, %v", body, err)
+	}
+
+	srv.Close()
+	if _, err := client.Call("GET", "/stat"); err == nil || !strings.Contains(err.Error(), "can't reach the instance at unix:") {
+		t.Errorf("after the server stopped: %v", err)
+	}
+}
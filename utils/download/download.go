@@ -0,0 +1,191 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package download
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultClient is used for release downloads. No overall timeout, archives
+// can be large; a stalled server is caught by the header timeout.
+var DefaultClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		ResponseHeaderTimeout: 30 * time.Second,
+	},
+}
+
+// Get returns the body of a small document such as a manifest or a signature
+func Get(client *http.Client, url string) ([]byte, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("GET " + url + ": " + resp.Status)
+	}
+	return ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// File downloads url to dst. The data is written to dst+".part" first, an
+// existing ".part" file from an interrupted download is resumed with a Range
+// request. dst appears only when the download is complete.
+func File(client *http.Client, url string, dst string) error {
+	part := dst + ".part"
+
+	var offset int64
+	if info, err := os.Stat(part); err == nil {
+		offset = info.Size()
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_WRONLY | os.O_CREATE
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		if !rangeStartsAt(resp.Header.Get("Content-Range"), offset) {
+			// not the part we asked for, appending it would corrupt the file
+			resp.Body.Close()
+			if err := os.Remove(part); err != nil {
+				return err
+			}
+			return File(client, url, dst)
+		}
+		flags |= os.O_APPEND
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// the part file is already complete
+		return os.Rename(part, dst)
+	case resp.StatusCode == http.StatusOK:
+		// no range support or nothing to resume, start over
+		flags |= os.O_TRUNC
+	default:
+		return errors.New("GET " + url + ": " + resp.Status)
+	}
+
+	f, err := os.OpenFile(part, flags, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		return fmt.Errorf("download %s interrupted, will resume: %w", url, err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(part, dst)
+}
+
+// rangeStartsAt tells whether a Content-Range header, "bytes 100-199/200",
+// starts at offset
+func rangeStartsAt(contentRange string, offset int64) bool {
+	spec := strings.TrimPrefix(strings.TrimSpace(contentRange), "bytes ")
+	sep := strings.Index(spec, "-")
+	if sep < 0 {
+		return false
+	}
+	start, err := strconv.ParseInt(spec[:sep], 10, 64)
+	return err == nil && start == offset
+}
+
+// SHA256File returns the hex encoded sha256 sum of the file
+func SHA256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// VerifySHA256 checks the file against a hex encoded sha256 sum
+func VerifySHA256(path string, expected string) error {
+	sum, err := SHA256File(path)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(sum, strings.TrimSpace(expected)) {
+		return errors.New("sha256 mismatch for " + path + ": expected " + expected + ", got " + sum)
+	}
+	return nil
+}
+
+// ParsePublicKey decodes a hex or base64 encoded ed25519 public key
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	s = strings.TrimSpace(s)
+	key, err := hex.DecodeString(s)
+	if err != nil {
+		key, err = base64.StdEncoding.DecodeString(s)
+	}
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid ed25519 public key")
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// VerifySignature checks a base64 (or hex) encoded ed25519 signature of data
+func VerifySignature(key ed25519.PublicKey, data []byte, signature string) error {
+	signature = strings.TrimSpace(signature)
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		sig, err = hex.DecodeString(signature)
+	}
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return errors.New("malformed ed25519 signature")
+	}
+	if !ed25519.Verify(key, data, sig) {
+		return errors.New("ed25519 signature verification failed")
+	}
+	return nil
+}
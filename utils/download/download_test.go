@@ -0,0 +1,85 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package download
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestFileResume(t *testing.T) {
+	const content = "0123456789"
+
+	tests := []struct {
+		name        string
+		fromOffset  bool // the 206 answer starts at the requested offset
+		rangeHeader bool // and says so in Content-Range
+	}{
+		{"resumed", true, true},
+		{"wrong start", false, true},
+		{"no content range", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				rng := r.Header.Get("Range")
+				if rng == "" {
+					w.Write([]byte(content))
+					return
+				}
+
+				start := 0
+				if tt.fromOffset {
+					start, _ = strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(rng, "bytes="), "-"))
+				}
+				if tt.rangeHeader {
+					w.Header().Set("Content-Range", "bytes "+strconv.Itoa(start)+"-9/10")
+				}
+				w.WriteHeader(http.StatusPartialContent)
+				w.Write([]byte(content[start:]))
+			}))
+			defer srv.Close()
+
+			dst := filepath.Join(t.TempDir(), "miner.tar.gz")
+			if err := ioutil.WriteFile(dst+".part", []byte(content[:5]), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := File(srv.Client(), srv.URL, dst); err != nil {
+				t.Fatal(err)
+			}
+			data, err := ioutil.ReadFile(dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != content {
+				t.Errorf("downloaded %q, want %q", data, content)
+			}
+		})
+	}
+}
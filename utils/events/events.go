@@ -0,0 +1,126 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package events is the in-process bus behind the "/events" stream
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// event types
+const (
+	TaskSwitch     = "task_switch"     // the pool's task list changed
+	ShareFound     = "share_found"     // a miner found a share, sent to the pool
+	ShareAccepted  = "share_accepted"  // the pool accepted it
+	ShareRejected  = "share_rejected"  // the pool rejected it
+	MinerStarted   = "miner_started"   // a miner process started on a task
+	MinerExited    = "miner_exited"    // a miner process exited
+	MinerRestarted = "miner_restarted" // a miner was stopped or crashed and is started again
+	Hashrate       = "hashrate"        // hashrate sample of all gpus
+	PoolError      = "pool_error"      // a request to the pool failed
+)
+
+type Event struct {
+	Id   uint64      `json:"id"`
+	Type string      `json:"type"`
+	Time int64       `json:"time"` // unix milliseconds
+	Gpu  int         `json:"gpu"`  // index as in /stat, -1 if not about one gpu
+	Data interface{} `json:"data,omitempty"`
+}
+
+// backlogSize events are kept for subscribers that reconnect
+const backlogSize = 1024
+
+// subscriberBuffer events are queued per subscriber, more are dropped
+const subscriberBuffer = 256
+
+type Subscription struct {
+	C       <-chan Event
+	Backlog []Event // kept events replayed by Subscribe
+	c       chan Event
+}
+
+var bus struct {
+	sync.Mutex
+	lastId      uint64
+	backlog     []Event
+	subscribers map[*Subscription]struct{}
+}
+
+// Publish sends an event to the subscribers. It never blocks: a
+// subscriber that doesn't keep up misses events.
+func Publish(typ string, gpu int, data interface{}) {
+	bus.Lock()
+	defer bus.Unlock()
+
+	bus.lastId++
+	e := Event{
+		Id:   bus.lastId,
+		Type: typ,
+		Time: time.Now().UnixNano() / int64(time.Millisecond),
+		Gpu:  gpu,
+		Data: data,
+	}
+
+	bus.backlog = append(bus.backlog, e)
+	if len(bus.backlog) > backlogSize {
+		bus.backlog = bus.backlog[len(bus.backlog)-backlogSize:]
+	}
+
+	for s := range bus.subscribers {
+		select {
+		case s.c <- e:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a subscription to the events published from now on.
+// With replay the kept events with an id above lastId are in Backlog.
+func Subscribe(lastId uint64, replay bool) *Subscription {
+	bus.Lock()
+	defer bus.Unlock()
+
+	c := make(chan Event, subscriberBuffer)
+	s := &Subscription{C: c, c: c}
+	if replay {
+		for _, e := range bus.backlog {
+			if e.Id > lastId {
+				s.Backlog = append(s.Backlog, e)
+			}
+		}
+	}
+
+	if bus.subscribers == nil {
+		bus.subscribers = map[*Subscription]struct{}{}
+	}
+	bus.subscribers[s] = struct{}{}
+	return s
+}
+
+// Close stops the subscription
+func (s *Subscription) Close() {
+	bus.Lock()
+	defer bus.Unlock()
+	delete(bus.subscribers, s)
+}
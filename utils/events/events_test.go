@@ -0,0 +1,127 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package events
+
+import "testing"
+
+func resetBus() {
+	bus.Lock()
+	defer bus.Unlock()
+	bus.lastId, bus.backlog, bus.subscribers = 0, nil, nil
+}
+
+func ids(events []Event) []uint64 {
+	var res []uint64
+	for _, e := range events {
+		res = append(res, e.Id)
+	}
+	return res
+}
+
+func TestBacklog(t *testing.T) {
+	tests := []struct {
+		name      string
+		published int
+		lastId    uint64
+		replay    bool
+		first     uint64 // id of the first replayed event, 0 for none
+		count     int
+	}{
+		{"no replay", 10, 0, false, 0, 0},
+		{"replay all", 10, 0, true, 1, 10},
+		{"after last event id", 10, 7, true, 8, 3},
+		{"nothing missed", 10, 10, true, 0, 0},
+		{"trimmed", backlogSize + 100, 0, true, 101, backlogSize},
+		{"last id trimmed away", backlogSize + 100, 50, true, 101, backlogSize},
+		{"last id in the trimmed backlog", backlogSize + 100, backlogSize, true, backlogSize + 1, 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetBus()
+			for i := 0; i < tt.published; i++ {
+				Publish(Hashrate, -1, nil)
+			}
+
+			s := Subscribe(tt.lastId, tt.replay)
+			defer s.Close()
+			if len(s.Backlog) != tt.count {
+				t.Fatalf("replayed %d events, want %d", len(s.Backlog), tt.count)
+			}
+			if tt.count > 0 && s.Backlog[0].Id != tt.first {
+				t.Errorf("first replayed id %d, want %d", s.Backlog[0].Id, tt.first)
+			}
+			for i := 1; i < len(s.Backlog); i++ {
+				if s.Backlog[i].Id != s.Backlog[i-1].Id+1 {
+					t.Fatalf("replayed ids not consecutive: %v", ids(s.Backlog))
+				}
+			}
+		})
+	}
+}
+
+func TestSubscriberDrops(t *testing.T) {
+	tests := []struct {
+		name      string
+		published int
+		received  int
+	}{
+		{"within the buffer", 10, 10},
+		{"buffer full", subscriberBuffer, subscriberBuffer},
+		{"slow subscriber", subscriberBuffer + 50, subscriberBuffer},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetBus()
+			slow := Subscribe(0, false)
+			defer slow.Close()
+
+			// Publish must not block on a subscriber that doesn't read
+			for i := 0; i < tt.published; i++ {
+				Publish(ShareFound, 0, map[string]interface{}{"task_id": i})
+			}
+
+			var got []Event
+			for len(slow.C) > 0 {
+				got = append(got, <-slow.C)
+			}
+			if len(got) != tt.received {
+				t.Fatalf("received %d events, want %d", len(got), tt.received)
+			}
+			// the oldest ones are kept, later ones dropped
+			if got[0].Id != 1 || got[len(got)-1].Id != uint64(tt.received) {
+				t.Errorf("received ids %d..%d, want 1..%d", got[0].Id, got[len(got)-1].Id, tt.received)
+			}
+		})
+	}
+}
+
+func TestClose(t *testing.T) {
+	resetBus()
+	s := Subscribe(0, false)
+	s.Close()
+	Publish(PoolError, -1, nil)
+	if len(s.C) != 0 {
+		t.Error("closed subscription still receives events")
+	}
+}
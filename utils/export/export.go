@@ -0,0 +1,189 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package export writes the miner stats in the formats of the rig operating
+// systems (HiveOS, MMPOS, RaveOS, minerstat)
+package export
+
+import (
+	"encoding/json"
+	"errors"
+	"miningPoolCli/config"
+	"miningPoolCli/utils/files"
+	"miningPoolCli/utils/gpuwrk"
+	"miningPoolCli/utils/mlog"
+	"miningPoolCli/utils/pci"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StatsFileName is written to the data directory with -stats, the exported
+// formats are rendered from it
+const StatsFileName = "stats.json"
+
+// MaxAge is how old the stats may be before the miner is considered down
+// and they are reported empty
+const MaxAge = time.Minute
+
+// Stale tells whether the stats were last updated more than maxAge ago
+func Stale(s gpuwrk.Stats, maxAge time.Duration) bool {
+	return time.Since(time.Unix(s.Updated, 0)) > maxAge
+}
+
+// Exporter renders the stats for one rig OS. The stats are empty when the
+// miner isn't running.
+type Exporter func(s gpuwrk.Stats) interface{}
+
+var Exporters = map[string]Exporter{
+	"hiveos":    HiveOS,
+	"mmpos":     MMPOS,
+	"raveos":    RaveOS,
+	"minerstat": Minerstat,
+}
+
+// Names lists the exporters for help and error messages
+func Names() []string {
+	var names []string
+	for name := range Exporters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FileName is where the format is written with -stats-format
+func FileName(format string) string {
+	return "stats-" + format + ".json"
+}
+
+// Render returns the stats in the format as json
+func Render(format string, s gpuwrk.Stats) ([]byte, error) {
+	exporter, ok := Exporters[format]
+	if !ok {
+		return nil, errors.New("unknown stats format \"" + format + "\", expected one of " + strings.Join(Names(), ", "))
+	}
+	return json.Marshal(exporter(s))
+}
+
+// CheckFormats validates -stats-format
+func CheckFormats(formats []string) error {
+	for _, format := range formats {
+		if _, ok := Exporters[format]; !ok {
+			return errors.New("unknown -stats-format \"" + format + "\", expected one of " + strings.Join(Names(), ", "))
+		}
+	}
+	return nil
+}
+
+// writeFailing is set while the stats files can't be written, to log it once
+var writeFailing bool
+
+// WriteFiles writes StatsFileName and the files of the -stats-format formats.
+// They are replaced atomically, the stats subcommands read them meanwhile.
+func WriteFiles(s gpuwrk.Stats) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		mlog.LogFatalStackError(err)
+	}
+	failed := files.WriteAtomic(config.DataPath(StatsFileName), data, 0644)
+
+	for _, format := range config.StatsFormats {
+		data, err := Render(format, s)
+		if err != nil {
+			mlog.LogFatalStackError(err)
+		}
+		if err := files.WriteAtomic(config.DataPath(FileName(format)), data, 0644); failed == nil {
+			failed = err
+		}
+	}
+
+	if failed != nil && !writeFailing {
+		mlog.LogError("Can't write the stats: " + failed.Error())
+	}
+	writeFailing = failed != nil
+}
+
+// gpu is one entry of the stats arrays
+type gpu struct {
+	BusId     string
+	Bus       int // decimal PCI bus, -1 if unknown
+	Model     string
+	Hashrate  int // Mh/s
+	Temp, Fan int
+	Power     int
+	Accepted  int
+	Rejected  int
+}
+
+// busNumber is the decimal PCI bus of "0000:01:00.0", -1 if unknown
+func busNumber(busId string) int {
+	parts := strings.Split(pci.NormalizeBusId(busId), ":")
+	if len(parts) != 3 {
+		return -1
+	}
+	n, err := strconv.ParseInt(parts[1], 16, 0)
+	if err != nil {
+		return -1
+	}
+	return int(n)
+}
+
+// gpus unpacks the stats ordered by PCI bus, the order the rig OSes number
+// the gpus in. allBuses is false if the bus of a gpu is unknown, the stats
+// order is kept then.
+func gpus(s gpuwrk.Stats) (res []gpu, allBuses bool) {
+	at := func(values []int, i int) int {
+		if i < len(values) {
+			return values[i]
+		}
+		return 0
+	}
+	str := func(values []string, i int) string {
+		if i < len(values) {
+			return values[i]
+		}
+		return ""
+	}
+
+	allBuses = true
+	for i := range s.Hs {
+		g := gpu{
+			BusId:    pci.NormalizeBusId(str(s.BusIds, i)),
+			Model:    str(s.Models, i),
+			Hashrate: s.Hs[i],
+			Temp:     at(s.Temp, i),
+			Fan:      at(s.Fan, i),
+			Power:    at(s.Power, i),
+			Accepted: at(s.Accepted, i),
+			Rejected: at(s.Rejected, i),
+		}
+		g.Bus = busNumber(g.BusId)
+		allBuses = allBuses && g.Bus >= 0
+		res = append(res, g)
+	}
+
+	if allBuses {
+		sort.SliceStable(res, func(a, b int) bool { return res[a].BusId < res[b].BusId })
+	}
+	return res, allBuses
+}
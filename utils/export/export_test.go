@@ -0,0 +1,108 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package export
+
+import (
+	"bytes"
+	"io/ioutil"
+	"miningPoolCli/config"
+	"miningPoolCli/utils/gpuwrk"
+	"miningPoolCli/utils/mlog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRender(t *testing.T) {
+	for _, format := range Names() {
+		if _, err := Render(format, rigStats()); err != nil {
+			t.Errorf("Render(%s): %v", format, err)
+		}
+	}
+	if _, err := Render("nicehash", rigStats()); err == nil || !strings.Contains(err.Error(), "hiveos, minerstat, mmpos, raveos") {
+		t.Errorf("Render(nicehash) = %v, want an error listing the formats", err)
+	}
+
+	if err := CheckFormats([]string{"mmpos", "raveos"}); err != nil {
+		t.Error(err)
+	}
+	if err := CheckFormats([]string{"mmpos", "MMPOS"}); err == nil {
+		t.Error("CheckFormats accepted MMPOS")
+	}
+}
+
+func TestStale(t *testing.T) {
+	tests := []struct {
+		age  time.Duration
+		want bool
+	}{
+		{0, false},
+		{MaxAge - 5*time.Second, false},
+		{MaxAge + 5*time.Second, true},
+		{time.Since(time.Unix(0, 0)), true}, // never written
+	}
+
+	for _, tt := range tests {
+		s := gpuwrk.Stats{Updated: time.Now().Add(-tt.age).Unix()}
+		if got := Stale(s, MaxAge); got != tt.want {
+			t.Errorf("Stale(%v old) = %v, want %v", tt.age, got, tt.want)
+		}
+	}
+}
+
+func TestWriteFiles(t *testing.T) {
+	savedDir, savedFormats := config.Paths.DataDir, config.StatsFormats
+	defer func() { config.Paths.DataDir, config.StatsFormats, writeFailing = savedDir, savedFormats, false }()
+	var log bytes.Buffer
+	mlog.SetOutput(&log)
+	defer mlog.SetOutput(os.Stdout)
+
+	config.Paths.DataDir = t.TempDir()
+	config.StatsFormats = []string{"mmpos", "raveos"}
+
+	WriteFiles(rigStats())
+	for _, name := range []string{StatsFileName, FileName("mmpos"), FileName("raveos")} {
+		if _, err := os.Stat(filepath.Join(config.Paths.DataDir, name)); err != nil {
+			t.Error(err)
+		}
+	}
+	data, _ := ioutil.ReadFile(filepath.Join(config.Paths.DataDir, FileName("mmpos")))
+	if want, _ := Render("mmpos", rigStats()); !bytes.Equal(data, want) {
+		t.Errorf("%s = %s, want %s", FileName("mmpos"), data, want)
+	}
+
+	// the error is logged once while it persists, and again after a recovery
+	dir := config.Paths.DataDir
+	config.Paths.DataDir = filepath.Join(dir, "missing")
+	WriteFiles(rigStats())
+	WriteFiles(rigStats())
+	config.Paths.DataDir = dir
+	WriteFiles(rigStats())
+	config.Paths.DataDir = filepath.Join(dir, "missing")
+	WriteFiles(rigStats())
+
+	if n := strings.Count(log.String(), "Can't write the stats"); n != 2 {
+		t.Errorf("write error logged %d times, want 2:\n%s", n, log.String())
+	}
+}
@@ -0,0 +1,75 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package export
+
+import (
+	"miningPoolCli/config"
+	"miningPoolCli/utils/gpuwrk"
+)
+
+// hiveStats is the json h-stats.sh hands to HiveOS, plus the total hashrate
+// it reports separately as khs
+type hiveStats struct {
+	Khs        float64 `json:"khs"` // total, kH/s
+	Hs         []int   `json:"hs"`
+	HsUnits    string  `json:"hs_units"`
+	Temp       []int   `json:"temp"`
+	Fan        []int   `json:"fan"`
+	Uptime     int64   `json:"uptime"`
+	Ar         []int   `json:"ar"`                    // accepted, rejected
+	BusNumbers []*int  `json:"bus_numbers,omitempty"` // null if unknown
+	Algo       string  `json:"algo"`
+	Ver        string  `json:"ver"`
+}
+
+// HiveOS custom miner stats. The bus number of a gpu whose bus is unknown is
+// null, h-stats.sh and HiveOS go by position for it.
+func HiveOS(s gpuwrk.Stats) interface{} {
+	res := hiveStats{
+		Khs:     float64(s.Khs) * 1000,
+		Hs:      []int{},
+		HsUnits: "mhs",
+		Temp:    []int{},
+		Fan:     []int{},
+		Uptime:  s.Uptime,
+		Ar:      []int{0, 0},
+		Algo:    "sha256",
+		Ver:     config.BuildVersion,
+	}
+
+	list, _ := gpus(s)
+	for _, g := range list {
+		res.Hs = append(res.Hs, g.Hashrate)
+		res.Temp = append(res.Temp, g.Temp)
+		res.Fan = append(res.Fan, g.Fan)
+		res.Ar[0] += g.Accepted
+		res.Ar[1] += g.Rejected
+		var bus *int
+		if g.Bus >= 0 {
+			n := g.Bus
+			bus = &n
+		}
+		res.BusNumbers = append(res.BusNumbers, bus)
+	}
+
+	return res
+}
@@ -0,0 +1,79 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package export
+
+import (
+	"encoding/json"
+	"miningPoolCli/config"
+	"miningPoolCli/utils/gpuwrk"
+	"testing"
+)
+
+// rigStats is a rig whose miners list the gpus out of PCI order
+func rigStats() gpuwrk.Stats {
+	return gpuwrk.Stats{
+		Khs:      5,
+		Uptime:   120,
+		Hs:       []int{3, 2},
+		Temp:     []int{61, 0},
+		Fan:      []int{70, 0},
+		Power:    []int{150, 120},
+		BusIds:   []string{"0000:0a:00.0", "00000000:01:00.0"},
+		Models:   []string{"RTX 3070", "RTX 3060"},
+		Accepted: []int{4, 6},
+		Rejected: []int{1, 0},
+	}
+}
+
+func TestHiveOS(t *testing.T) {
+	saved := config.BuildVersion
+	config.BuildVersion = "1.0"
+	defer func() { config.BuildVersion = saved }()
+
+	unknownBus := rigStats()
+	unknownBus.BusIds = []string{"0000:0a:00.0", ""}
+
+	tests := []struct {
+		name  string
+		stats gpuwrk.Stats
+		want  string
+	}{
+		{"sorted by bus", rigStats(),
+			`{"khs":5000,"hs":[2,3],"hs_units":"mhs","temp":[0,61],"fan":[0,70],"uptime":120,"ar":[10,1],` +
+				`"bus_numbers":[1,10],"algo":"sha256","ver":"1.0"}`},
+		{"unknown bus", unknownBus,
+			`{"khs":5000,"hs":[3,2],"hs_units":"mhs","temp":[61,0],"fan":[70,0],"uptime":120,"ar":[10,1],` +
+				`"bus_numbers":[10,null],"algo":"sha256","ver":"1.0"}`},
+		{"miner down", gpuwrk.Stats{},
+			`{"khs":0,"hs":[],"hs_units":"mhs","temp":[],"fan":[],"uptime":0,"ar":[0,0],"algo":"sha256","ver":"1.0"}`},
+	}
+
+	for _, tt := range tests {
+		data, err := json.Marshal(HiveOS(tt.stats))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("%s:\n got %s\nwant %s", tt.name, data, tt.want)
+		}
+	}
+}
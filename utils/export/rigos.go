@@ -0,0 +1,172 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package export
+
+import (
+	"miningPoolCli/config"
+	"miningPoolCli/utils/gpuwrk"
+)
+
+type mmposStats struct {
+	BusId        []string `json:"busid"`
+	Hash         []int    `json:"hash"`
+	Units        string   `json:"units"`
+	Air          [][3]int `json:"air"` // accepted, invalid, rejected per gpu
+	Temp         []int    `json:"temp"`
+	Fan          []int    `json:"fan"`
+	Power        []int    `json:"power"`
+	Uptime       int64    `json:"uptime"`
+	MinerName    string   `json:"miner_name"`
+	MinerVersion string   `json:"miner_version"`
+}
+
+// MMPOS agent stats of a custom miner
+func MMPOS(s gpuwrk.Stats) interface{} {
+	res := mmposStats{
+		BusId:        []string{},
+		Hash:         []int{},
+		Units:        "mhs",
+		Air:          [][3]int{},
+		Temp:         []int{},
+		Fan:          []int{},
+		Power:        []int{},
+		Uptime:       s.Uptime,
+		MinerName:    "miningPoolCli",
+		MinerVersion: config.BuildVersion,
+	}
+
+	list, _ := gpus(s)
+	for _, g := range list {
+		res.BusId = append(res.BusId, g.BusId)
+		res.Hash = append(res.Hash, g.Hashrate)
+		res.Air = append(res.Air, [3]int{g.Accepted, 0, g.Rejected})
+		res.Temp = append(res.Temp, g.Temp)
+		res.Fan = append(res.Fan, g.Fan)
+		res.Power = append(res.Power, g.Power)
+	}
+
+	return res
+}
+
+type raveosGpu struct {
+	BusId    string `json:"bus_id"`
+	Model    string `json:"model"`
+	Hashrate int64  `json:"hashrate"` // H/s
+	Accepted int    `json:"accepted"`
+	Rejected int    `json:"rejected"`
+	Temp     int    `json:"temp"`
+	Fan      int    `json:"fan"`
+	Power    int    `json:"power"`
+}
+
+type raveosStats struct {
+	Miner         string      `json:"miner"`
+	Version       string      `json:"version"`
+	Algorithm     string      `json:"algorithm"`
+	Uptime        int64       `json:"uptime"`
+	TotalHashrate int64       `json:"total_hashrate"` // H/s
+	Accepted      int         `json:"accepted"`
+	Rejected      int         `json:"rejected"`
+	Gpus          []raveosGpu `json:"gpus"`
+}
+
+// RaveOS miner API response
+func RaveOS(s gpuwrk.Stats) interface{} {
+	res := raveosStats{
+		Miner:     "miningPoolCli",
+		Version:   config.BuildVersion,
+		Algorithm: "sha256",
+		Uptime:    s.Uptime,
+		Gpus:      []raveosGpu{},
+	}
+
+	list, _ := gpus(s)
+	for _, g := range list {
+		hashrate := int64(g.Hashrate) * 1000000
+		res.Gpus = append(res.Gpus, raveosGpu{
+			BusId:    g.BusId,
+			Model:    g.Model,
+			Hashrate: hashrate,
+			Accepted: g.Accepted,
+			Rejected: g.Rejected,
+			Temp:     g.Temp,
+			Fan:      g.Fan,
+			Power:    g.Power,
+		})
+		res.TotalHashrate += hashrate
+		res.Accepted += g.Accepted
+		res.Rejected += g.Rejected
+	}
+
+	return res
+}
+
+type minerstatDevice struct {
+	BusId    string  `json:"bus_id"`
+	Name     string  `json:"name"`
+	Hashrate float64 `json:"hashrate"`
+	Temp     int     `json:"temperature"`
+	Fan      int     `json:"fan"`
+	Power    int     `json:"power"`
+}
+
+type minerstatStats struct {
+	Hashrate struct {
+		Units string  `json:"units"`
+		Total float64 `json:"total"`
+	} `json:"hashrate"`
+	Shares struct {
+		Accepted int `json:"accepted"`
+		Rejected int `json:"rejected"`
+	} `json:"shares"`
+	Devices []minerstatDevice `json:"devices"`
+	Uptime  int64             `json:"uptime"`
+	Algo    string            `json:"algo"`
+	Version string            `json:"version"`
+}
+
+// Minerstat custom miner output
+func Minerstat(s gpuwrk.Stats) interface{} {
+	var res minerstatStats
+	res.Hashrate.Units = "MH"
+	res.Devices = []minerstatDevice{}
+	res.Uptime = s.Uptime
+	res.Algo = "sha256"
+	res.Version = config.BuildVersion
+
+	list, _ := gpus(s)
+	for _, g := range list {
+		res.Devices = append(res.Devices, minerstatDevice{
+			BusId:    g.BusId,
+			Name:     g.Model,
+			Hashrate: float64(g.Hashrate),
+			Temp:     g.Temp,
+			Fan:      g.Fan,
+			Power:    g.Power,
+		})
+		res.Hashrate.Total += float64(g.Hashrate)
+		res.Shares.Accepted += g.Accepted
+		res.Shares.Rejected += g.Rejected
+	}
+
+	return res
+}
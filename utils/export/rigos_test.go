@@ -0,0 +1,81 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package export
+
+import (
+	"encoding/json"
+	"miningPoolCli/config"
+	"miningPoolCli/utils/gpuwrk"
+	"strings"
+	"testing"
+)
+
+func TestRigOSFormats(t *testing.T) {
+	saved := config.BuildVersion
+	config.BuildVersion = "1.0"
+	defer func() { config.BuildVersion = saved }()
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"mmpos", `{"busid":["0000:01:00.0","0000:0a:00.0"],"hash":[2,3],"units":"mhs","air":[[6,0,0],[4,0,1]],` +
+			`"temp":[0,61],"fan":[0,70],"power":[120,150],"uptime":120,"miner_name":"miningPoolCli","miner_version":"1.0"}`},
+		{"raveos", `{"miner":"miningPoolCli","version":"1.0","algorithm":"sha256","uptime":120,"total_hashrate":5000000,` +
+			`"accepted":10,"rejected":1,"gpus":[` +
+			`{"bus_id":"0000:01:00.0","model":"RTX 3060","hashrate":2000000,"accepted":6,"rejected":0,"temp":0,"fan":0,"power":120},` +
+			`{"bus_id":"0000:0a:00.0","model":"RTX 3070","hashrate":3000000,"accepted":4,"rejected":1,"temp":61,"fan":70,"power":150}]}`},
+		{"minerstat", `{"hashrate":{"units":"MH","total":5},"shares":{"accepted":10,"rejected":1},"devices":[` +
+			`{"bus_id":"0000:01:00.0","name":"RTX 3060","hashrate":2,"temperature":0,"fan":0,"power":120},` +
+			`{"bus_id":"0000:0a:00.0","name":"RTX 3070","hashrate":3,"temperature":61,"fan":70,"power":150}],` +
+			`"uptime":120,"algo":"sha256","version":"1.0"}`},
+	}
+
+	for _, tt := range tests {
+		data, err := json.Marshal(Exporters[tt.format](rigStats()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("%s:\n got %s\nwant %s", tt.format, data, tt.want)
+		}
+	}
+}
+
+func TestRigOSFormatsMinerDown(t *testing.T) {
+	// the rig OSes expect empty arrays, not null, while the miner is down
+	tests := map[string]string{
+		"mmpos":     `"busid":[]`,
+		"raveos":    `"gpus":[]`,
+		"minerstat": `"devices":[]`,
+	}
+
+	for format, want := range tests {
+		data, err := json.Marshal(Exporters[format](gpuwrk.Stats{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), want) {
+			t.Errorf("%s: %s does not contain %s", format, data, want)
+		}
+	}
+}
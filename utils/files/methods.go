@@ -25,6 +25,7 @@ import (
 	"io/ioutil"
 	"miningPoolCli/utils/mlog"
 	"os"
+	"path/filepath"
 )
 
 func GetDir(path string) []string {
@@ -46,3 +47,25 @@ func RemovePath(strDir string) {
 		mlog.LogFatal("error: RemovePath(): " + err.Error())
 	}
 }
+
+// WriteAtomic replaces the file at path with data, readers never see a
+// partially written file
+func WriteAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
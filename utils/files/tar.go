@@ -24,15 +24,57 @@ package files
 import (
 	"archive/tar"
 	"compress/gzip"
+	"errors"
 	"io"
-	"miningPoolCli/utils/mlog"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 )
 
-func ExtractTarGz(gzipStream io.Reader, pathToExtarct string) {
+// inside tells if target is dir or a path in it
+func inside(dir, target string) bool {
+	dir = filepath.Clean(dir)
+	return target == dir || strings.HasPrefix(target, dir+string(os.PathSeparator))
+}
+
+// noSymlinks fails if a directory on the way from dir to target, or target
+// itself if last is set, is a symlink extracted before. Writing through one
+// could end up outside of dir even though the path stays inside.
+func noSymlinks(dir, target string, last bool) error {
+	rel, err := filepath.Rel(filepath.Clean(dir), target)
+	if err != nil || rel == "." {
+		return err
+	}
+	parts := strings.Split(rel, string(os.PathSeparator))
+	if !last {
+		parts = parts[:len(parts)-1]
+	}
+
+	path := filepath.Clean(dir)
+	for _, part := range parts {
+		path = filepath.Join(path, part)
+		info, err := os.Lstat(path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return errors.New("path goes through the symlink " + path)
+		}
+	}
+	return nil
+}
+
+// ExtractTarGz extracts the archive into pathToExtarct. Entries and links
+// that point outside of it, or are written through a symlink of the
+// archive, are rejected.
+func ExtractTarGz(gzipStream io.Reader, pathToExtarct string) error {
 	uncompressedStream, err := gzip.NewReader(gzipStream)
 	if err != nil {
-		mlog.LogFatal("ExtractTarGz: NewReader failed: " + err.Error())
+		return errors.New("ExtractTarGz: NewReader failed: " + err.Error())
 	}
 
 	tarReader := tar.NewReader(uncompressedStream)
@@ -45,27 +87,71 @@ func ExtractTarGz(gzipStream io.Reader, pathToExtarct string) {
 		}
 
 		if err != nil {
-			mlog.LogError("ExtractTarGz: Next() failed: " + err.Error())
+			return errors.New("ExtractTarGz: Next() failed: " + err.Error())
+		}
+
+		target := filepath.Join(pathToExtarct, header.Name)
+		if !inside(pathToExtarct, target) {
+			return errors.New("ExtractTarGz: invalid file path: " + header.Name)
+		}
+		// links are replaced, files and directories must not be followed
+		replaced := header.Typeflag == tar.TypeSymlink || header.Typeflag == tar.TypeLink
+		if err := noSymlinks(pathToExtarct, target, !replaced); err != nil {
+			return errors.New("ExtractTarGz: " + header.Name + ": " + err.Error())
 		}
 
 		switch header.Typeflag {
 		case tar.TypeDir:
-			if err := os.Mkdir(pathToExtarct+"/"+header.Name, 0755); err != nil {
-				mlog.LogFatal("ExtractTarGz: Mkdir() failed:" + err.Error())
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return errors.New("ExtractTarGz: Mkdir() failed: " + err.Error())
 			}
 		case tar.TypeReg:
-			outFile, err := os.Create(pathToExtarct + "/" + header.Name)
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return errors.New("ExtractTarGz: Mkdir() failed: " + err.Error())
+			}
+			outFile, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.FileMode(header.Mode).Perm())
 			if err != nil {
-				mlog.LogFatal("ExtractTarGz: Create() failed: " + err.Error())
+				return errors.New("ExtractTarGz: Create() failed: " + err.Error())
 			}
 			if _, err := io.Copy(outFile, tarReader); err != nil {
-				mlog.LogFatal("ExtractTarGz: Copy() failed: " + err.Error())
+				outFile.Close()
+				return errors.New("ExtractTarGz: Copy() failed: " + err.Error())
 			}
 			outFile.Close()
 
+		case tar.TypeSymlink:
+			// relative to the link's directory, absolute targets are refused
+			linkTarget := filepath.Join(filepath.Dir(target), header.Linkname)
+			if filepath.IsAbs(header.Linkname) || !inside(pathToExtarct, linkTarget) {
+				return errors.New("ExtractTarGz: symlink " + header.Name + " points outside: " + header.Linkname)
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return errors.New("ExtractTarGz: Mkdir() failed: " + err.Error())
+			}
+			os.Remove(target)
+			if err := os.Symlink(header.Linkname, target); err != nil {
+				return errors.New("ExtractTarGz: Symlink() failed: " + err.Error())
+			}
+		case tar.TypeLink:
+			// relative to the archive root
+			linkTarget := filepath.Join(pathToExtarct, header.Linkname)
+			if !inside(pathToExtarct, linkTarget) {
+				return errors.New("ExtractTarGz: hard link " + header.Name + " points outside: " + header.Linkname)
+			}
+			if err := noSymlinks(pathToExtarct, linkTarget, true); err != nil {
+				return errors.New("ExtractTarGz: hard link " + header.Name + ": " + err.Error())
+			}
+			os.Remove(target)
+			if err := os.Link(linkTarget, target); err != nil {
+				return errors.New("ExtractTarGz: Link() failed: " + err.Error())
+			}
+
 		default:
-			mlog.LogFatal("ExtractTarGz: uknown type: " + string(header.Typeflag) + " in " + header.Name)
+			return errors.New("ExtractTarGz: unsupported entry " + header.Name +
+				" (type " + strconv.Quote(string(header.Typeflag)) + "), only files, directories and links can be extracted")
 		}
 
 	}
+
+	return nil
 }
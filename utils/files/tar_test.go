@@ -0,0 +1,126 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package files
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type tarEntry struct {
+	name, body, link string
+	typ              byte
+}
+
+func tarGz(t *testing.T, entries []tarEntry) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Typeflag: e.typ, Mode: 0644, Linkname: e.link}
+		switch e.typ {
+		case tar.TypeDir:
+			hdr.Mode = 0755
+		case tar.TypeReg:
+			hdr.Size = int64(len(e.body))
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if e.typ == tar.TypeReg {
+			tw.Write([]byte(e.body))
+		}
+	}
+	tw.Close()
+	gz.Close()
+	return &buf
+}
+
+func TestExtractTarGzRootEntry(t *testing.T) {
+	dst := t.TempDir()
+	archive := tarGz(t, []tarEntry{
+		{name: "./", typ: tar.TypeDir},
+		{name: "./bin/", typ: tar.TypeDir},
+		{name: "./bin/pow-miner-cuda", body: "cuda", typ: tar.TypeReg},
+		{name: "./pow-miner", link: "bin/pow-miner-cuda", typ: tar.TypeSymlink},
+	})
+
+	if err := ExtractTarGz(archive, dst); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dst, "pow-miner"))
+	if err != nil || string(data) != "cuda" {
+		t.Fatalf("pow-miner = %q, %v", data, err)
+	}
+}
+
+func TestExtractTarGzRejectsOutside(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []tarEntry
+		err     string
+	}{
+		{"parent file", []tarEntry{{name: "../evil", body: "x", typ: tar.TypeReg}}, "invalid file path"},
+		{"nested parent", []tarEntry{{name: "./a/../../evil", body: "x", typ: tar.TypeReg}}, "invalid file path"},
+		{"symlink to parent", []tarEntry{{name: "link", link: "../../etc/passwd", typ: tar.TypeSymlink}}, "points outside"},
+		{"absolute symlink", []tarEntry{{name: "link", link: "/etc/passwd", typ: tar.TypeSymlink}}, "points outside"},
+		{"hard link to parent", []tarEntry{{name: "link", link: "../evil", typ: tar.TypeLink}}, "points outside"},
+		{"fifo", []tarEntry{{name: "fifo", typ: tar.TypeFifo}}, "unsupported entry"},
+		// each link stays inside on its own, d1/d2/x resolves to the parent
+		{"chained symlinks", []tarEntry{
+			{name: "d1", link: ".", typ: tar.TypeSymlink},
+			{name: "d1/d2/x", link: "../..", typ: tar.TypeSymlink},
+			{name: "d1/d2/x/evil", body: "x", typ: tar.TypeReg},
+		}, "through the symlink"},
+		{"file over a symlink", []tarEntry{
+			{name: "d1", link: ".", typ: tar.TypeSymlink},
+			{name: "d1", body: "x", typ: tar.TypeReg},
+		}, "through the symlink"},
+		{"hard link to a symlink", []tarEntry{
+			{name: "d1", link: ".", typ: tar.TypeSymlink},
+			{name: "d2", link: "d1", typ: tar.TypeLink},
+		}, "through the symlink"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parent := t.TempDir()
+			dst := filepath.Join(parent, "dst")
+			os.Mkdir(dst, 0755)
+
+			err := ExtractTarGz(tarGz(t, tt.entries), dst)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("err = %v, want %q", err, tt.err)
+			}
+			if _, err := os.Lstat(filepath.Join(parent, "evil")); err == nil {
+				t.Fatal("file written outside of the destination")
+			}
+		})
+	}
+}
@@ -23,46 +23,49 @@ package files
 
 import (
 	"archive/zip"
+	"errors"
 	"io"
-	"miningPoolCli/utils/mlog"
 	"os"
 	"path/filepath"
 	"strings"
 )
 
 // Extract ZIP files, but skip all directories in zip
-func ExtractZip(gzipStream io.ReaderAt, size int64, dst string) {
+func ExtractZip(gzipStream io.ReaderAt, size int64, dst string) error {
 	archive, err := zip.NewReader(gzipStream, size)
-	// archive, err := zip.OpenReader(filename)
 	if err != nil {
-		mlog.LogFatal("error while opening ''; " + err.Error())
+		return errors.New("ExtractZip: error while opening archive; " + err.Error())
 	}
-	// defer archive.Close()
 
 	for _, f := range archive.File {
 		filePath := filepath.Join(dst, f.Name)
 
 		if !strings.HasPrefix(filePath, filepath.Clean(dst)+string(os.PathSeparator)) {
-			mlog.LogError("ExtractZip invalid file path: " + filePath)
-			return
+			return errors.New("ExtractZip invalid file path: " + filePath)
+		}
+		if f.FileInfo().IsDir() {
+			continue
 		}
 
 		c := filepath.Join(dst, filepath.Base(filePath))
 		dstFile, err := os.OpenFile(c, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
 		if err != nil {
-			mlog.LogFatalStackError(err)
+			return err
 		}
 
 		fileInArchive, err := f.Open()
 		if err != nil {
-			mlog.LogFatalStackError(err)
-		}
-
-		if _, err := io.Copy(dstFile, fileInArchive); err != nil {
-			mlog.LogFatalStackError(err)
+			dstFile.Close()
+			return err
 		}
 
+		_, err = io.Copy(dstFile, fileInArchive)
 		dstFile.Close()
 		fileInArchive.Close()
+		if err != nil {
+			return err
+		}
 	}
+
+	return nil
 }
@@ -22,11 +22,12 @@ along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
 package getminer
 
 import (
+	"errors"
 	"miningPoolCli/config"
-	"miningPoolCli/utils/files"
-	"miningPoolCli/utils/helpers"
+	"miningPoolCli/utils/download"
 	"miningPoolCli/utils/mlog"
 	"os"
+	"path/filepath"
 )
 
 func GetMiner() {
@@ -34,84 +35,111 @@ func GetMiner() {
 
 	switch config.OS.OperatingSystem {
 	case config.OSType.Linux:
-		// minerReleaseURL = config.MinerGetter.UbuntuSettings.ReleaseURL
-		// minerFileName = config.MinerGetter.UbuntuSettings.FileName
 		executableName = config.MinerGetter.UbuntuSettings.ExecutableName
 		executableNameCuda = config.MinerGetter.UbuntuSettings.ExecutableNameCuda
 	case config.OSType.Win:
-		// minerReleaseURL = config.MinerGetter.WinSettings.ReleaseURL
-		// minerFileName = config.MinerGetter.WinSettings.FileName
 		executableName = config.MinerGetter.WinSettings.ExecutableName
 		executableNameCuda = config.MinerGetter.WinSettings.ExecutableNameCuda
 	case config.OSType.Macos:
-		// minerReleaseURL = config.MinerGetter.MacSettings.ReleaseURL
-		// minerFileName = config.MinerGetter.MacSettings.FileName
 		executableName = config.MinerGetter.MacSettings.ExecutableName
 		executableNameCuda = config.MinerGetter.MacSettings.ExecutableNameCuda
 	}
 
-	mlog.LogInfo("Starting to download the miner for a " + config.OS.OperatingSystem + " system")
-
-	if !helpers.StringInSlice(config.MinerGetter.MinerDirectory, files.GetDir(".")) {
-		mlog.LogFatal("\"" + config.MinerGetter.MinerDirectory + "\"" + " not exists. It's needed to start miner. Download miner again pleases.")
-		// files.RemovePath(config.MinerGetter.MinerDirectory)
+	if config.MinerGetter.SkipVerify {
+		mlog.LogInfo("warn: -skip-miner-verify is set, miner binaries are not verified")
+	} else {
+		var required []string
+		for _, exe := range []string{executableName, executableNameCuda} {
+			if exe != "" {
+				required = append(required, exe)
+			}
+		}
+		if err := VerifyDir(config.Paths.MinerDir, required); err != nil {
+			mlog.LogFatal("Miner integrity check failed: " + err.Error())
+		}
+		mlog.LogOk("Miner binaries verified")
 	}
 
-	// getFileResp, err := api.GetReqAttempt(minerReleaseURL)
-	// if err != nil {
-	// 	mlog.LogFatalStackError(err)
-	// }
-
-	// if minerFileName != "" {
-	// 	if helpers.StringInSlice(minerFileName, files.GetDir(".")) {
-	// 		mlog.LogOk("Download completed \"" + getFileResp.Filename + "\"")
-	// 	} else {
-	// 		mlog.LogFatal("Something went wrong. " + minerFileName + " not found in this catalog")
-	// 	}
-	// } else {
-	// 	if helpers.StringInSlice(getFileResp.Filename, files.GetDir(".")) {
-	// 		mlog.LogOk("Download completed \"" + getFileResp.Filename + "\"")
-	// 	} else {
-	// 		mlog.LogFatal("Something went wrong. " + getFileResp.Filename + " not found in this catalog")
-	// 	}
-	// }
-
-	// if err := os.Mkdir(config.MinerGetter.MinerDirectory, 0755); err != nil {
-	// 	mlog.LogFatal(err.Error())
-	// }
-	// if err := os.Cp
-
-	// switch config.OS.OperatingSystem {
-	// case config.OSType.Linux:
-	// 	// r, err := os.Open(minerFileName)
-	// 	// if err != nil {
-	// 	// 	mlog.LogFatal("error while opening '" + minerFileName + "'; " + err.Error())
-	// 	// }
-
-	// 	buff := bytes.NewBuffer(getFileResp)
-	// 	files.ExtractTarGz(buff, config.MinerGetter.MinerDirectory)
-	// case config.OSType.Win:
-	// 	files.ExtractZip(bytes.NewReader(getFileResp), int64(len(getFileResp)), config.MinerGetter.MinerDirectory)
-	// case config.OSType.Macos:
-	// 	files.CopyFile(executableName, config.MinerGetter.MinerDirectory+"/"+executableName)
-	// }
-
 	if config.OS.OperatingSystem == config.OSType.Linux || config.OS.OperatingSystem == config.OSType.Macos && executableName != "" {
-		os.Chmod(config.MinerGetter.MinerDirectory+"/"+executableName, 0700)
+		os.Chmod(filepath.Join(config.Paths.MinerDir, executableName), 0700)
 	}
 	if config.OS.OperatingSystem == config.OSType.Linux || config.OS.OperatingSystem == config.OSType.Macos && executableNameCuda != "" {
-		os.Chmod(config.MinerGetter.MinerDirectory+"/"+executableNameCuda, 0700)
+		os.Chmod(filepath.Join(config.Paths.MinerDir, executableNameCuda), 0700)
+	}
+}
+
+// ManagedRoot is the directory the releases from the manifest are installed into
+func ManagedRoot() string {
+	return config.DataPath("miners")
+}
+
+// NewFetcher returns a Fetcher for the -miner-manifest release manifest
+func NewFetcher() (*Fetcher, error) {
+	if config.MinerGetter.ManifestKey == "" {
+		return nil, errors.New("-miner-manifest-key is not set, miner download is disabled")
+	}
+	key, err := download.ParsePublicKey(config.MinerGetter.ManifestKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var required []string
+	for _, exe := range []string{config.MinerGetter.CurrExecNameOpenCL, config.MinerGetter.CurrExecNameCuda} {
+		if exe != "" {
+			required = append(required, exe)
+		}
+	}
+
+	return &Fetcher{
+		ManifestURL: config.MinerGetter.ManifestURL,
+		PublicKey:   key,
+		Root:        ManagedRoot(),
+		OS:          config.OS.OperatingSystem,
+		Arch:        config.OS.Architecture,
+		Required:    required,
+		Client:      download.DefaultClient,
+	}, nil
+}
+
+// ManagedMinerDir applies -miner-rollback and -miner-update and returns the
+// directory of the active downloaded release, or "" if there is none
+func ManagedMinerDir() string {
+	root := ManagedRoot()
+
+	if config.MinerGetter.Rollback {
+		dir, err := Rollback(root)
+		if err != nil {
+			mlog.LogFatal("Miner rollback failed: " + err.Error())
+		}
+		mlog.LogOk("Rolled back to miner " + filepath.Base(dir))
 	}
 
-	// if minerFileName != "" {
-	// 	files.RemovePath(minerFileName)
-	// } else {
-	// 	files.RemovePath(getFileResp.Filename)
-	// }
-
-	// if helpers.StringInSlice(executableName, files.GetDir(config.MinerGetter.MinerDirectory)) {
-	// 	mlog.LogOk("The miner is saved in the directory: " + "\"" + config.MinerGetter.MinerDirectory + "\"")
-	// } else {
-	// 	mlog.LogFatal("Something went wrong. Miner not found in" + "\"" + config.MinerGetter.MinerDirectory + "\"")
-	// }
+	if config.MinerGetter.Update {
+		if dir, err := DownloadMiner(); err != nil {
+			mlog.LogError("Miner update failed: " + err.Error())
+		} else {
+			return dir
+		}
+	}
+
+	if current, err := Current(root); err == nil {
+		return filepath.Join(root, current)
+	}
+	return ""
+}
+
+// DownloadMiner installs the release from the manifest and makes it current
+func DownloadMiner() (string, error) {
+	fetcher, err := NewFetcher()
+	if err != nil {
+		return "", err
+	}
+
+	mlog.LogInfo("Checking miner release manifest " + fetcher.ManifestURL)
+	dir, err := fetcher.Update()
+	if err != nil {
+		return "", err
+	}
+	mlog.LogOk("Using miner " + filepath.Base(dir))
+	return dir, nil
 }
@@ -0,0 +1,271 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package getminer
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"miningPoolCli/utils/download"
+	"miningPoolCli/utils/files"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Manifest lists the miner archives of a release. It is published together
+// with a detached ed25519 signature at ManifestURL + ".sig".
+//
+//	{"miners": [{"os": "linux", "arch": "amd64", "version": "2024.05",
+//	  "url": "https://.../miners-linux.tar.gz", "sha256": "...", "format": "tar.gz"}]}
+type Manifest struct {
+	Miners []MinerRelease `json:"miners"`
+}
+
+type MinerRelease struct {
+	OS      string `json:"os"`
+	Arch    string `json:"arch"`
+	Version string `json:"version"`
+	URL     string `json:"url"`
+	SHA256  string `json:"sha256"`
+	Format  string `json:"format"` // "tar.gz", "zip" or "bin" (a single executable)
+}
+
+const (
+	currentFile  = "current"
+	previousFile = "previous"
+	downloadDir  = ".download"
+)
+
+var versionPat = regexp.MustCompile(`^[0-9A-Za-z][0-9A-Za-z._-]*$`)
+
+// Fetcher installs miner releases into Root/<version> and keeps the names of
+// the active and the previous version in Root/current and Root/previous.
+type Fetcher struct {
+	ManifestURL string
+	PublicKey   ed25519.PublicKey
+	Root        string
+	OS, Arch    string
+	Required    []string // executables the release must contain
+	Client      *http.Client
+}
+
+// FetchManifest downloads the manifest and verifies its signature
+func (f *Fetcher) FetchManifest() (Manifest, error) {
+	var manifest Manifest
+
+	body, err := download.Get(f.Client, f.ManifestURL)
+	if err != nil {
+		return manifest, err
+	}
+	sig, err := download.Get(f.Client, f.ManifestURL+".sig")
+	if err != nil {
+		return manifest, errors.New("can't get manifest signature: " + err.Error())
+	}
+	if err := download.VerifySignature(f.PublicKey, body, string(sig)); err != nil {
+		return manifest, errors.New("manifest " + f.ManifestURL + ": " + err.Error())
+	}
+
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return manifest, errors.New("can't parse manifest: " + err.Error())
+	}
+	return manifest, nil
+}
+
+// Release returns the manifest entry for the fetcher's OS and architecture
+func (f *Fetcher) Release(manifest Manifest) (MinerRelease, error) {
+	for _, rel := range manifest.Miners {
+		if rel.OS == f.OS && rel.Arch == f.Arch {
+			if !versionPat.MatchString(rel.Version) {
+				return rel, errors.New("invalid miner version in manifest: \"" + rel.Version + "\"")
+			}
+			return rel, nil
+		}
+	}
+	return MinerRelease{}, errors.New("no miner for " + f.OS + "/" + f.Arch + " in manifest")
+}
+
+// Update installs the release from the manifest unless it is already the
+// current one and returns the directory of the active version
+func (f *Fetcher) Update() (string, error) {
+	manifest, err := f.FetchManifest()
+	if err != nil {
+		return "", err
+	}
+	rel, err := f.Release(manifest)
+	if err != nil {
+		return "", err
+	}
+
+	if current, _ := Current(f.Root); current == rel.Version {
+		return filepath.Join(f.Root, current), nil
+	}
+
+	if err := f.Install(rel); err != nil {
+		return "", err
+	}
+	if err := activate(f.Root, rel.Version); err != nil {
+		return "", err
+	}
+	return filepath.Join(f.Root, rel.Version), nil
+}
+
+// Install downloads, verifies and extracts the release into Root/<version>.
+// The files are extracted into a temporary directory which is renamed into
+// place only when everything is checked, an existing version is kept as is.
+func (f *Fetcher) Install(rel MinerRelease) error {
+	dst := filepath.Join(f.Root, rel.Version)
+	if _, err := os.Stat(dst); err == nil {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Join(f.Root, downloadDir), 0755); err != nil {
+		return err
+	}
+
+	name := path.Base(rel.URL)
+	if !versionPat.MatchString(name) {
+		name = "miner-" + rel.Version
+	}
+	archive := filepath.Join(f.Root, downloadDir, rel.Version+"-"+name)
+	if err := download.File(f.Client, rel.URL, archive); err != nil {
+		return err
+	}
+	defer os.Remove(archive)
+
+	if err := download.VerifySHA256(archive, rel.SHA256); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempDir(f.Root, "."+rel.Version+"-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	if err := extract(archive, rel, tmp); err != nil {
+		return err
+	}
+
+	for _, exe := range f.Required {
+		if _, err := os.Stat(filepath.Join(tmp, exe)); err != nil {
+			return errors.New("miner release " + rel.Version + " does not contain " + exe)
+		}
+		if err := os.Chmod(filepath.Join(tmp, exe), 0755); err != nil {
+			return err
+		}
+	}
+
+	// the archive is already verified, record its files for the startup check
+	if _, err := os.Stat(filepath.Join(tmp, ChecksumFile)); os.IsNotExist(err) {
+		if err := WriteChecksums(tmp); err != nil {
+			return err
+		}
+	} else if err := VerifyDir(tmp, f.Required); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, dst)
+}
+
+func extract(archive string, rel MinerRelease, dst string) error {
+	switch strings.ToLower(rel.Format) {
+	case "tar.gz", "tgz":
+		r, err := os.Open(archive)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		return files.ExtractTarGz(r, dst)
+	case "zip":
+		r, err := os.Open(archive)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		info, err := r.Stat()
+		if err != nil {
+			return err
+		}
+		return files.ExtractZip(r, info.Size(), dst)
+	case "bin", "":
+		data, err := ioutil.ReadFile(archive)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(filepath.Join(dst, path.Base(rel.URL)), data, 0755)
+	default:
+		return errors.New("unknown miner archive format \"" + rel.Format + "\"")
+	}
+}
+
+func readVersionFile(root, name string) (string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(root, name))
+	if err != nil {
+		return "", err
+	}
+	version := strings.TrimSpace(string(data))
+	if !versionPat.MatchString(version) {
+		return "", errors.New("invalid version in " + filepath.Join(root, name))
+	}
+	return version, nil
+}
+
+// writeVersionFile replaces root/name atomically
+func writeVersionFile(root, name, version string) error {
+	return files.WriteAtomic(filepath.Join(root, name), []byte(version+"\n"), 0644)
+}
+
+// Current returns the active installed version
+func Current(root string) (string, error) {
+	return readVersionFile(root, currentFile)
+}
+
+// activate makes version the current one and remembers the replaced version
+// for Rollback
+func activate(root, version string) error {
+	if current, err := Current(root); err == nil && current != version {
+		if err := writeVersionFile(root, previousFile, current); err != nil {
+			return err
+		}
+	}
+	return writeVersionFile(root, currentFile, version)
+}
+
+// Rollback switches back to the previous version and returns its directory
+func Rollback(root string) (string, error) {
+	previous, err := readVersionFile(root, previousFile)
+	if err != nil {
+		return "", errors.New("no previous miner version to roll back to")
+	}
+	if _, err := os.Stat(filepath.Join(root, previous)); err != nil {
+		return "", errors.New("previous miner version " + previous + " is not installed")
+	}
+	if err := activate(root, previous); err != nil {
+		return "", err
+	}
+	return filepath.Join(root, previous), nil
+}
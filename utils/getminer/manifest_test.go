@@ -0,0 +1,222 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package getminer
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// minerArchive is a tar.gz with the miner executable, as made by
+// "tar -C dir -czf archive ."
+func minerArchive(t *testing.T, body string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	tw.WriteHeader(&tar.Header{Name: "./", Typeflag: tar.TypeDir, Mode: 0755})
+	tw.WriteHeader(&tar.Header{Name: "./pow-miner-opencl", Typeflag: tar.TypeReg, Mode: 0755, Size: int64(len(body))})
+	tw.Write([]byte(body))
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+// releaseServer serves a signed manifest and the archives of the releases
+type releaseServer struct {
+	*httptest.Server
+	key      ed25519.PrivateKey
+	files    map[string][]byte
+	releases []MinerRelease
+}
+
+func newReleaseServer(t *testing.T) *releaseServer {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &releaseServer{key: key, files: map[string][]byte{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := s.files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// publish adds a release; the manifest lists the last published one
+func (s *releaseServer) publish(t *testing.T, version string, archive []byte, sum string) {
+	t.Helper()
+	name := "/miners-" + version + ".tar.gz"
+	s.files[name] = archive
+	if sum == "" {
+		h := sha256.Sum256(archive)
+		sum = hex.EncodeToString(h[:])
+	}
+	rel := MinerRelease{OS: "linux", Arch: "amd64", Version: version, URL: s.URL + name, SHA256: sum, Format: "tar.gz"}
+
+	manifest, _ := json.Marshal(Manifest{Miners: []MinerRelease{rel}})
+	s.files["/manifest.json"] = manifest
+	s.files["/manifest.json.sig"] = []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(s.key, manifest)))
+}
+
+func (s *releaseServer) fetcher(root string) *Fetcher {
+	return &Fetcher{
+		ManifestURL: s.URL + "/manifest.json",
+		PublicKey:   s.key.Public().(ed25519.PublicKey),
+		Root:        root,
+		OS:          "linux",
+		Arch:        "amd64",
+		Required:    []string{"pow-miner-opencl"},
+		Client:      s.Client(),
+	}
+}
+
+func TestFetchManifest(t *testing.T) {
+	s := newReleaseServer(t)
+	s.publish(t, "2024.05", minerArchive(t, "miner"), "")
+	f := s.fetcher(t.TempDir())
+
+	manifest, err := f.FetchManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rel, err := f.Release(manifest)
+	if err != nil || rel.Version != "2024.05" {
+		t.Fatalf("release = %+v, %v", rel, err)
+	}
+
+	// signed by another key
+	_, other, _ := ed25519.GenerateKey(rand.Reader)
+	s.files["/manifest.json.sig"] = []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(other, s.files["/manifest.json"])))
+	if _, err := f.FetchManifest(); err == nil || !strings.Contains(err.Error(), "verification failed") {
+		t.Fatalf("err = %v, want a signature error", err)
+	}
+
+	delete(s.files, "/manifest.json.sig")
+	if _, err := f.FetchManifest(); err == nil {
+		t.Fatal("manifest accepted without signature")
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	s := newReleaseServer(t)
+	s.publish(t, "2024.05", minerArchive(t, "miner"), "")
+	root := t.TempDir()
+	f := s.fetcher(root)
+
+	dir, err := f.Update()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir != filepath.Join(root, "2024.05") {
+		t.Fatalf("dir = %s", dir)
+	}
+	if err := VerifyDir(dir, f.Required); err != nil {
+		t.Fatal(err)
+	}
+	if current, _ := Current(root); current != "2024.05" {
+		t.Fatalf("current = %q", current)
+	}
+
+	// already current, nothing is downloaded
+	delete(s.files, "/miners-2024.05.tar.gz")
+	if _, err := f.Update(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestChecksumMismatch(t *testing.T) {
+	s := newReleaseServer(t)
+	s.publish(t, "2024.05", minerArchive(t, "miner"), strings.Repeat("0", 64))
+	root := t.TempDir()
+
+	if _, err := s.fetcher(root).Update(); err == nil {
+		t.Fatal("archive with a wrong checksum installed")
+	}
+	if _, err := os.Stat(filepath.Join(root, "2024.05")); !os.IsNotExist(err) {
+		t.Fatal("version directory created")
+	}
+	if _, err := Current(root); err == nil {
+		t.Fatal("current version set")
+	}
+}
+
+func TestRollback(t *testing.T) {
+	s := newReleaseServer(t)
+	root := t.TempDir()
+	f := s.fetcher(root)
+
+	if _, err := Rollback(root); err == nil {
+		t.Fatal("rollback without a previous version")
+	}
+
+	s.publish(t, "2024.05", minerArchive(t, "old"), "")
+	if _, err := f.Update(); err != nil {
+		t.Fatal(err)
+	}
+	s.publish(t, "2024.06", minerArchive(t, "new"), "")
+	if _, err := f.Update(); err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := Rollback(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir != filepath.Join(root, "2024.05") {
+		t.Fatalf("dir = %s", dir)
+	}
+	if current, _ := Current(root); current != "2024.05" {
+		t.Fatalf("current = %q", current)
+	}
+	data, _ := ioutil.ReadFile(filepath.Join(dir, "pow-miner-opencl"))
+	if string(data) != "old" {
+		t.Fatalf("miner = %q", data)
+	}
+
+	// the rolled back version becomes the previous one
+	if _, err := Rollback(root); err != nil {
+		t.Fatal(err)
+	}
+	if current, _ := Current(root); current != "2024.06" {
+		t.Fatalf("current = %q", current)
+	}
+}
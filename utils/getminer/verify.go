@@ -0,0 +1,125 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package getminer
+
+import (
+	"bufio"
+	"errors"
+	"io/ioutil"
+	"miningPoolCli/utils/download"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ChecksumFile lists the expected sha256 of every file in a miner directory,
+// in the format of the sha256sum tool
+const ChecksumFile = "SHA256SUMS"
+
+func readChecksums(dir string) (map[string]string, error) {
+	f, err := os.Open(filepath.Join(dir, ChecksumFile))
+	if err != nil {
+		return nil, errors.New("can't read checksum manifest: " + err.Error())
+	}
+	defer f.Close()
+
+	sums := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) != 2 {
+			return nil, errors.New("malformed line in " + ChecksumFile + ": " + line)
+		}
+		// "*" marks binary mode in sha256sum output
+		name := strings.TrimPrefix(strings.TrimSpace(fields[1]), "*")
+		sums[filepath.FromSlash(name)] = fields[0]
+	}
+
+	return sums, scanner.Err()
+}
+
+// VerifyDir checks every file listed in dir/SHA256SUMS. The required
+// executables must be listed, an unlisted miner is an error too.
+func VerifyDir(dir string, required []string) error {
+	sums, err := readChecksums(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, exe := range required {
+		if _, ok := sums[exe]; !ok {
+			return errors.New(filepath.Join(dir, exe) + " is not listed in " + ChecksumFile)
+		}
+	}
+
+	names := make([]string, 0, len(sums))
+	for name := range sums {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		sum, err := download.SHA256File(filepath.Join(dir, name))
+		if err != nil {
+			return errors.New("can't verify " + filepath.Join(dir, name) + ": " + err.Error())
+		}
+		if !strings.EqualFold(sum, sums[name]) {
+			return errors.New(filepath.Join(dir, name) + " does not match " + ChecksumFile +
+				": expected " + sums[name] + ", got " + sum)
+		}
+	}
+
+	return nil
+}
+
+// WriteChecksums creates dir/SHA256SUMS for all files in dir
+func WriteChecksums(dir string) error {
+	var lines []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		name, err := filepath.Rel(dir, path)
+		if err != nil || name == ChecksumFile {
+			return err
+		}
+
+		sum, err := download.SHA256File(path)
+		if err != nil {
+			return err
+		}
+		lines = append(lines, sum+"  "+filepath.ToSlash(name))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Strings(lines)
+	return ioutil.WriteFile(filepath.Join(dir, ChecksumFile), []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
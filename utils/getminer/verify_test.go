@@ -0,0 +1,93 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package getminer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func sha256Hex(s string) string {
+	h := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(h[:])
+}
+
+func TestVerifyDir(t *testing.T) {
+	miner := sha256Hex("miner")
+	tests := []struct {
+		name    string
+		sums    string // SHA256SUMS, not written if empty
+		wantErr string
+	}{
+		{"valid", miner + "  pow-miner-cuda\n", ""},
+		{"binary mode, comments and upper case", "# miners\n\n" + strings.ToUpper(miner) + " *pow-miner-cuda\n", ""},
+		{"no manifest", "", "can't read checksum manifest"},
+		{"malformed line", miner + "\n", "malformed line"},
+		{"required miner not listed", sha256Hex("lib") + "  lib.so\n", "is not listed"},
+		{"modified miner", sha256Hex("other") + "  pow-miner-cuda\n", "does not match"},
+		{"listed file missing", miner + "  pow-miner-cuda\n" + sha256Hex("lib") + "  lib.so\n", "can't verify"},
+	}
+
+	for _, tt := range tests {
+		dir := t.TempDir()
+		if err := ioutil.WriteFile(filepath.Join(dir, "pow-miner-cuda"), []byte("miner"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if tt.sums != "" {
+			if err := ioutil.WriteFile(filepath.Join(dir, ChecksumFile), []byte(tt.sums), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		err := VerifyDir(dir, []string{"pow-miner-cuda"})
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+		if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestWriteChecksums(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "lib"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "pow-miner-cuda"), []byte("miner"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "lib", "libcuda.so"), []byte("lib"), 0644)
+
+	if err := WriteChecksums(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyDir(dir, []string{"pow-miner-cuda", filepath.Join("lib", "libcuda.so")}); err != nil {
+		t.Fatal(err)
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, "lib", "libcuda.so"), []byte("patched"), 0644)
+	if err := VerifyDir(dir, []string{"pow-miner-cuda"}); err == nil {
+		t.Fatal("modified library accepted")
+	}
+}
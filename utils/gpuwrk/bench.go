@@ -0,0 +1,136 @@
+package gpuwrk
+
+import (
+	"bufio"
+	"bytes"
+	"math"
+	"miningPoolCli/config"
+	"miningPoolCli/utils/helpers"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// BenchTask is the synthetic work given to the miner. The complexity is so
+// low that no share is ever found and the miner keeps hashing.
+type BenchTask struct {
+	PoolAddress string
+	Seed        string // hex
+	Complexity  string // hex
+}
+
+// BenchResult of one gpu, hashrates in Mh/s
+type BenchResult struct {
+	Index int `json:"index"`
+	GPUstruct
+
+	Samples   int     `json:"samples"`
+	Min       float64 `json:"min"`
+	Avg       float64 `json:"avg"`
+	Max       float64 `json:"max"`
+	StdDev    float64 `json:"stddev"`
+	Stability float64 `json:"stability"` // %, 100 - coefficient of variation
+	Restarts  int     `json:"restarts"`  // miner runs after the first one
+	Error     string  `json:"error,omitempty"`
+}
+
+// Bench runs the miner of the gpu on the task for the duration, restarting
+// it when it exits (after its -t timeout), and collects the "instant speed"
+// lines. The first reading of every run is skipped, the miner is still
+// warming up.
+func Bench(gpu GPUstruct, task BenchTask, duration time.Duration) BenchResult {
+	res := BenchResult{GPUstruct: gpu}
+	var samples []float64
+
+	deadline := time.Now().Add(duration)
+	for run := 0; time.Now().Before(deadline); run++ {
+		cmd := MinerCommand(gpu, config.StaticBeforeMinerSettings.BoostFactor,
+			task.PoolAddress,
+			helpers.ConvertHexData(task.Seed),
+			helpers.ConvertHexData(task.Complexity),
+			config.StaticBeforeMinerSettings.Iterations,
+		)
+		stderr, err := cmd.StderrPipe()
+		if err != nil {
+			res.Error = err.Error()
+			break
+		}
+		if err := cmd.Start(); err != nil {
+			res.Error = err.Error()
+			break
+		}
+		if run > 0 {
+			res.Restarts++
+		}
+
+		var once sync.Once
+		stop := func() { once.Do(func() { cmd.Process.Kill() }) }
+		timer := time.AfterFunc(time.Until(deadline), stop)
+
+		scanner := bufio.NewScanner(stderr)
+		scanner.Split(scanLines)
+		first := true
+		for scanner.Scan() {
+			m := config.MRgxKit.FindHashRate.FindStringSubmatch(scanner.Text())
+			if m == nil {
+				continue
+			}
+			hs, err := strconv.ParseFloat(m[1], 64)
+			if err != nil {
+				continue
+			}
+			if first {
+				first = false
+				continue
+			}
+			samples = append(samples, hs)
+		}
+
+		timer.Stop()
+		cmd.Wait()
+
+		if first && time.Now().Before(deadline) {
+			res.Error = "the miner exited without reporting a hashrate"
+			break
+		}
+	}
+
+	res.Samples = len(samples)
+	if len(samples) == 0 {
+		if res.Error == "" {
+			res.Error = "no hashrate reported, try a longer -duration"
+		}
+		return res
+	}
+
+	res.Min, res.Max = samples[0], samples[0]
+	var sum float64
+	for _, s := range samples {
+		res.Min = math.Min(res.Min, s)
+		res.Max = math.Max(res.Max, s)
+		sum += s
+	}
+	res.Avg = sum / float64(len(samples))
+
+	var sq float64
+	for _, s := range samples {
+		sq += (s - res.Avg) * (s - res.Avg)
+	}
+	res.StdDev = math.Sqrt(sq / float64(len(samples)))
+	if res.Avg > 0 {
+		res.Stability = math.Max(0, 100-res.StdDev/res.Avg*100)
+	}
+
+	return res
+}
+
+// scanLines splits on "\n" and on "\r", the miner may redraw its status line
+func scanLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
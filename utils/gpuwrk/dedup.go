@@ -0,0 +1,184 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package gpuwrk
+
+import (
+	"errors"
+	"miningPoolCli/config"
+	"miningPoolCli/utils/pci"
+	"regexp"
+	"strconv"
+)
+
+const BackendAuto = "auto"
+
+var smPrefix = regexp.MustCompile(`^SM \d\.\d `)
+
+// gpuName is the gpu id as it's written in -gpus, "cuda:0" / "opencl:0:1"
+func gpuName(gpu GPUstruct) string {
+	if gpu.Backend == BackendCuda {
+		return BackendCuda + ":" + strconv.Itoa(gpu.GpuId)
+	}
+	return gpu.Backend + ":" + strconv.Itoa(gpu.PlatformId) + ":" + strconv.Itoa(gpu.GpuId)
+}
+
+// AssignBusIds fills BusId of the gpus the miner didn't report one for,
+// from the display controllers found in sysfs. The devices of a backend
+// platform are taken to be in PCI order: for CUDA this holds with
+// CUDA_DEVICE_ORDER=PCI_BUS_ID (cudaPciOrder), for OpenCL it's what the
+// drivers do. Nothing is assigned when the number of devices differs.
+func AssignBusIds(gpus []GPUstruct, devices []pci.Device, cudaPciOrder bool) {
+	type group struct{ backend, platform, vendor string }
+	groups := map[group][]int{}
+	var order []group
+
+	for i, gpu := range gpus {
+		vendor := pci.VendorNvidia
+		if gpu.Backend != BackendCuda {
+			vendor = pci.VendorOfModel(gpu.Model)
+		} else if !cudaPciOrder {
+			continue
+		}
+		if vendor == "" {
+			continue
+		}
+
+		g := group{gpu.Backend, strconv.Itoa(gpu.PlatformId), vendor}
+		if _, ok := groups[g]; !ok {
+			order = append(order, g)
+		}
+		groups[g] = append(groups[g], i)
+	}
+
+	for _, g := range order {
+		indexes := groups[g]
+		candidates := pci.ByVendor(devices, g.vendor)
+		if len(candidates) != len(indexes) {
+			continue
+		}
+
+		known := false
+		for _, i := range indexes {
+			if gpus[i].BusId != "" {
+				known = true
+			}
+		}
+		if known {
+			continue
+		}
+
+		for n, i := range indexes {
+			gpus[i].BusId = candidates[n].BusId
+		}
+	}
+}
+
+// backendPreference returns the backend to mine with when a gpu is seen by
+// both CUDA and OpenCL: the -gpu-backend entry for its bus id or -backend
+func backendPreference(busId string) string {
+	if busId != "" {
+		for bus, backend := range config.GpuSelect.BackendByBus {
+			if pci.NormalizeBusId(bus) == busId {
+				return backend
+			}
+		}
+	}
+	if config.GpuSelect.Backend == "" {
+		return BackendAuto
+	}
+	return config.GpuSelect.Backend
+}
+
+// CheckBackendPreferences validates -backend and -gpu-backend
+func CheckBackendPreferences(backend string, byBus map[string]string) error {
+	check := func(backend string) error {
+		switch backend {
+		case BackendAuto, BackendCuda, BackendOpenCL:
+			return nil
+		}
+		return errors.New("invalid backend \"" + backend + "\", expected auto, cuda or opencl")
+	}
+
+	if err := check(backend); err != nil {
+		return err
+	}
+	for bus, backend := range byBus {
+		if pci.NormalizeBusId(bus) == "" {
+			return errors.New("invalid PCI bus id \"" + bus + "\" in -gpu-backend")
+		}
+		if err := check(backend); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func excludeDuplicate(gpus []GPUstruct, cudaIdx, openCLIdx int, reason string) {
+	if backendPreference(gpus[cudaIdx].BusId) == BackendOpenCL {
+		gpus[cudaIdx].Excluded = "same device as " + gpuName(gpus[openCLIdx]) + " (" + reason + "), backend preference opencl"
+	} else {
+		gpus[openCLIdx].Excluded = "same device as " + gpuName(gpus[cudaIdx]) + " (" + reason + ")"
+	}
+}
+
+// DedupBackends excludes one of each pair of CUDA and OpenCL entries that
+// are the same card. Cards are matched by PCI bus id; an OpenCL device
+// without a known bus id falls back to the model name, pairing with one of
+// the CUDA devices that are not matched yet.
+func DedupBackends(gpus []GPUstruct) {
+	cudaByBus := map[string]int{}
+	matched := map[int]bool{}
+
+	for i, gpu := range gpus {
+		if gpu.Backend == BackendCuda && gpu.Excluded == "" && gpu.BusId != "" {
+			cudaByBus[gpu.BusId] = i
+		}
+	}
+
+	for i, gpu := range gpus {
+		if gpu.Backend != BackendOpenCL || gpu.Excluded != "" || gpu.BusId == "" {
+			continue
+		}
+		if c, ok := cudaByBus[gpu.BusId]; ok {
+			excludeDuplicate(gpus, c, i, "PCI bus "+gpu.BusId)
+			matched[c] = true
+		}
+	}
+
+	cudaByModel := map[string][]int{}
+	for i, gpu := range gpus {
+		if gpu.Backend == BackendCuda && gpu.Excluded == "" && !matched[i] {
+			model := smPrefix.ReplaceAllString(gpu.Model, "")
+			cudaByModel[model] = append(cudaByModel[model], i)
+		}
+	}
+
+	for i, gpu := range gpus {
+		if gpu.Backend != BackendOpenCL || gpu.Excluded != "" || gpu.BusId != "" {
+			continue
+		}
+		if same := cudaByModel[gpu.Model]; len(same) > 0 {
+			excludeDuplicate(gpus, same[0], i, "same model, bus id unknown")
+			cudaByModel[gpu.Model] = same[1:]
+		}
+	}
+}
@@ -0,0 +1,151 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package gpuwrk
+
+import (
+	"miningPoolCli/config"
+	"miningPoolCli/utils/pci"
+	"testing"
+)
+
+func TestAssignBusIds(t *testing.T) {
+	devices := []pci.Device{
+		{BusId: "0000:01:00.0", Vendor: pci.VendorNvidia},
+		{BusId: "0000:02:00.0", Vendor: pci.VendorAMD},
+		{BusId: "0000:03:00.0", Vendor: pci.VendorNvidia},
+		{BusId: "0000:04:00.0", Vendor: pci.VendorIntel},
+	}
+	cuda := func(id int) GPUstruct {
+		return GPUstruct{GpuId: id, Model: "NVIDIA GeForce RTX 3060", Backend: BackendCuda}
+	}
+	opencl := func(platform, id int, model string) GPUstruct {
+		return GPUstruct{GpuId: id, PlatformId: platform, Model: model, Backend: BackendOpenCL}
+	}
+
+	tests := []struct {
+		name         string
+		gpus         []GPUstruct
+		cudaPciOrder bool
+		want         []string
+	}{
+		{"cuda in pci order", []GPUstruct{cuda(0), cuda(1)}, true, []string{"0000:01:00.0", "0000:03:00.0"}},
+		{"cuda in default order", []GPUstruct{cuda(0), cuda(1)}, false, []string{"", ""}},
+		{"count differs", []GPUstruct{cuda(0)}, true, []string{""}},
+		{"opencl per platform",
+			[]GPUstruct{opencl(0, 0, "Ellesmere"), opencl(1, 0, "GeForce RTX 3060"), opencl(1, 1, "GeForce RTX 3060")}, false,
+			[]string{"0000:02:00.0", "0000:01:00.0", "0000:03:00.0"}},
+		{"unknown vendor", []GPUstruct{opencl(0, 0, "Mystery GPU")}, false, []string{""}},
+		{"bus id reported by the miner",
+			[]GPUstruct{{GpuId: 0, Backend: BackendCuda, BusId: "0000:03:00.0"}, cuda(1)}, true,
+			[]string{"0000:03:00.0", ""}},
+	}
+
+	for _, tt := range tests {
+		AssignBusIds(tt.gpus, devices, tt.cudaPciOrder)
+		for i, gpu := range tt.gpus {
+			if gpu.BusId != tt.want[i] {
+				t.Errorf("%s: %s bus id = %q, want %q", tt.name, gpuName(gpu), gpu.BusId, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestDedupBackends(t *testing.T) {
+	cuda := func(id int, bus string) GPUstruct {
+		return GPUstruct{GpuId: id, Model: "SM 8.6 NVIDIA GeForce RTX 3060", Backend: BackendCuda, BusId: bus}
+	}
+	opencl := func(id int, bus string) GPUstruct {
+		return GPUstruct{GpuId: id, PlatformId: 1, Model: "NVIDIA GeForce RTX 3060", Backend: BackendOpenCL, BusId: bus}
+	}
+	rx := GPUstruct{GpuId: 0, Model: "AMD Radeon RX 580", Backend: BackendOpenCL, BusId: "0000:02:00.0"}
+
+	tests := []struct {
+		name    string
+		backend string
+		byBus   map[string]string
+		gpus    []GPUstruct
+		used    []string // gpuName of the gpus left for mining
+	}{
+		{"by bus id", "auto", nil,
+			[]GPUstruct{cuda(0, "0000:01:00.0"), cuda(1, "0000:03:00.0"), opencl(0, "0000:03:00.0"), opencl(1, "0000:01:00.0"), rx},
+			[]string{"cuda:0", "cuda:1", "opencl:0:0"}},
+		{"prefer opencl", "opencl", nil,
+			[]GPUstruct{cuda(0, "0000:01:00.0"), opencl(0, "0000:01:00.0")},
+			[]string{"opencl:1:0"}},
+		{"prefer opencl for one card", "auto", map[string]string{"03:00.0": "opencl"},
+			[]GPUstruct{cuda(0, "0000:01:00.0"), cuda(1, "0000:03:00.0"), opencl(0, "0000:01:00.0"), opencl(1, "0000:03:00.0")},
+			[]string{"cuda:0", "opencl:1:1"}},
+		{"by model without bus id", "auto", nil,
+			[]GPUstruct{cuda(0, ""), cuda(1, ""), opencl(0, ""), opencl(1, ""), opencl(2, "")},
+			[]string{"cuda:0", "cuda:1", "opencl:1:2"}},
+		{"model fallback skips cards matched by bus", "auto", nil,
+			[]GPUstruct{cuda(0, "0000:01:00.0"), cuda(1, ""), opencl(0, "0000:01:00.0"), opencl(1, "")},
+			[]string{"cuda:0", "cuda:1"}},
+		{"excluded cuda device", "auto", nil,
+			[]GPUstruct{{GpuId: 0, Backend: BackendCuda, BusId: "0000:01:00.0", Excluded: "-exclude-gpus"}, opencl(0, "0000:01:00.0")},
+			[]string{"opencl:1:0"}},
+	}
+
+	defer func() { config.GpuSelect.Backend, config.GpuSelect.BackendByBus = "", nil }()
+	for _, tt := range tests {
+		config.GpuSelect.Backend, config.GpuSelect.BackendByBus = tt.backend, tt.byBus
+
+		DedupBackends(tt.gpus)
+
+		var used []string
+		for _, gpu := range tt.gpus {
+			if gpu.Excluded == "" {
+				used = append(used, gpuName(gpu))
+			}
+		}
+		if len(used) != len(tt.used) {
+			t.Errorf("%s: used %q, want %q", tt.name, used, tt.used)
+			continue
+		}
+		for i := range used {
+			if used[i] != tt.used[i] {
+				t.Errorf("%s: used %q, want %q", tt.name, used, tt.used)
+				break
+			}
+		}
+	}
+}
+
+func TestCheckBackendPreferences(t *testing.T) {
+	tests := []struct {
+		backend string
+		byBus   map[string]string
+		ok      bool
+	}{
+		{"auto", nil, true},
+		{"cuda", map[string]string{"01:00.0": "opencl", "0000:02:00.0": "auto"}, true},
+		{"vulkan", nil, false},
+		{"auto", map[string]string{"gpu0": "cuda"}, false},
+		{"auto", map[string]string{"01:00.0": "metal"}, false},
+	}
+
+	for _, tt := range tests {
+		if err := CheckBackendPreferences(tt.backend, tt.byBus); (err == nil) != tt.ok {
+			t.Errorf("CheckBackendPreferences(%q, %v) = %v, want ok %v", tt.backend, tt.byBus, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package gpuwrk
+
+import (
+	"bytes"
+	"errors"
+	"miningPoolCli/config"
+	"miningPoolCli/utils/mlog"
+	"miningPoolCli/utils/pci"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// DiscoveryLine tells what discovery made of a line of the miner output
+type DiscoveryLine struct {
+	Backend  string `json:"backend"`
+	Line     string `json:"line"`
+	Accepted bool   `json:"accepted"`
+	Reason   string `json:"reason"`
+}
+
+// ParseGpuList finds the devices in the output of a miner started without
+// arguments. The miner prints "[ GPU #0: SM 8.6 NVIDIA ... ]" (CUDA) or
+// "[ OpenCL: platform #0 device #1 ... ]" (OpenCL) lines.
+func ParseGpuList(backend string, output string) ([]GPUstruct, []DiscoveryLine) {
+	var gpusArray []GPUstruct
+	var lines []DiscoveryLine
+
+	idsNeeded := 1
+	if backend == BackendOpenCL {
+		idsNeeded = 2
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		matches := config.MRgxKit.FindGPUPat.FindAllString(line, -1)
+		if len(matches) == 0 {
+			lines = append(lines, DiscoveryLine{backend, line, false, "no \"[ ... ]\" device pattern"})
+			continue
+		}
+
+		for _, v := range matches {
+			gpuModel := strings.TrimSpace(
+				config.MRgxKit.ReplaceEndGPU.ReplaceAllString(config.MRgxKit.ReplaceStartGPU.ReplaceAllString(v, ""), ""),
+			)
+
+			panddId := config.MRgxKit.FindIntIds.FindAllString(v, -1)
+			if len(panddId) < idsNeeded {
+				lines = append(lines, DiscoveryLine{backend, v, false,
+					"expected " + strconv.Itoa(idsNeeded) + " \"#N\" ids, found: " + strings.Join(panddId, ", ")})
+				continue
+			}
+
+			var ids []int
+			for _, idStr := range panddId[:idsNeeded] {
+				id, err := strconv.Atoi(strings.Replace(idStr, "#", "", -1))
+				if err != nil {
+					lines = append(lines, DiscoveryLine{backend, v, false, "invalid id " + idStr})
+					break
+				}
+				ids = append(ids, id)
+			}
+			if len(ids) < idsNeeded {
+				continue
+			}
+
+			gpu := GPUstruct{
+				GpuId:   ids[len(ids)-1],
+				Model:   gpuModel,
+				Backend: backend,
+				BusId:   pci.NormalizeBusId(config.MRgxKit.FindBusId.FindString(v)),
+			}
+			if backend == BackendOpenCL {
+				gpu.PlatformId = ids[0]
+			}
+
+			gpusArray = append(gpusArray, gpu)
+			lines = append(lines, DiscoveryLine{backend, v, true, "device " + gpuName(gpu) + " \"" + gpuModel + "\""})
+		}
+	}
+
+	return gpusArray, lines
+}
+
+func searchGpusWithRegex(backend string, execStr string) ([]GPUstruct, []DiscoveryLine, error) {
+	cmd := exec.Command(execStr)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Start(); err != nil {
+		return nil, nil, errors.New("can't run the " + backend + " miner: " + err.Error())
+	}
+	cmd.Wait()
+
+	if backend == BackendCuda {
+		mlog.LogInfo("CUDA Info: " + stderr.String())
+	} else {
+		mlog.LogInfo("OpenCL Info: " + stderr.String())
+	}
+
+	gpus, lines := ParseGpuList(backend, stderr.String())
+	return gpus, lines, nil
+}
+
+func SearchGpusCuda(execStr string) ([]GPUstruct, []DiscoveryLine, error) {
+	return searchGpusWithRegex(BackendCuda, execStr)
+}
+
+func SearchGpusOpenCL(execStr string) ([]GPUstruct, []DiscoveryLine, error) {
+	return searchGpusWithRegex(BackendOpenCL, execStr)
+}
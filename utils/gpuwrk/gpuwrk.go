@@ -23,207 +23,279 @@ package gpuwrk
 
 import (
 	"bytes"
-	"errors"
+	"fmt"
 	"miningPoolCli/config"
 	"miningPoolCli/utils/mlog"
+	"miningPoolCli/utils/telemetry"
+	"os"
 	"os/exec"
 	"strconv"
-	"strings"
+	"sync"
+)
+
+const (
+	BackendCuda   = "cuda"
+	BackendOpenCL = "opencl"
 )
 
 type GPUstruct struct {
 	GpuId      int    `json:"device_id"`
 	Model      string `json:"device_name"`
 	PlatformId int    `json:"platform_id"`
+	Backend    string `json:"backend"`
+	BusId      string `json:"bus_id,omitempty"` // PCI bus id, "0000:01:00.0", if known
 	StartPath  string `json:"start_path"`
+	Excluded   string `json:"excluded,omitempty"` // why the gpu is not used for mining
+	MaxTemp    int    `json:"max_temp,omitempty"` // -gpu-max-temp, overrides -max-temp
+
+	ExtraArgs []string `json:"extra_args,omitempty"` // appended to the miner flags
+	Env       []string `json:"-"`                    // KEY=VALUE added to the miner environment
 }
 
 type GpuGoroutine struct {
 	GpuData GPUstruct
 	// startTimestamp int64
 	CurrentHashrate int
+	Telemetry       telemetry.Reading
+
+	Accepted, Rejected int // shares answered by the pool
+
+	Retired  bool   // the gpu disappeared, the miner must not be started again
+	Thermal  string // state of the thermal guard, ThermalOk if not tripped
+	Paused   bool   // paused by the control api
+	Draining bool   // no new task after the current one
+
+	// the miner process, changed by StartMiner, StopMiner and MinerExited
+	procMu    sync.Mutex
+	PPid      int
+	TaskId    int          // pool task of the running miner
+	output    *MinerOutput // stderr of the last started miner
+	keepAlive bool         // start the next task when the miner exits
+	running   bool         // the miner process is running
+	stopped   bool         // the running miner was killed by StopMiner
+}
 
-	ProcStderr bytes.Buffer
-	PPid       int
-	KeepAlive  bool
+// MinerOutput collects the stderr of one miner process. It's read while the
+// miner writes to it.
+type MinerOutput struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
 }
 
-func LogGpuList(gpus []GPUstruct) {
-	var gpuNames []string
+func (o *MinerOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buf.Write(p)
+}
 
-	for i := 0; i < len(gpus); i++ {
-		gpuNames = append(gpuNames, gpus[i].Model)
-	}
+func (o *MinerOutput) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buf.String()
+}
 
-	dict := make(map[string]int)
-	for _, num := range gpuNames {
-		dict[num] = dict[num] + 1
-	}
+const (
+	StateRunning       = "running"
+	StateIdle          = "idle" // between two tasks
+	StatePaused        = "paused"
+	StateDraining      = "draining"
+	StateDrained       = "drained"
+	StateThermalPaused = "thermal-paused"
+	StateThrottled     = "throttled"
+	StateRetired       = "retired"
+)
 
-	mlog.LogInfo("Found GPUs:")
-	for model, count := range dict {
-		mlog.LogInfo("x" + strconv.Itoa(count) + " " + model + "\n")
+// CountShare counts a share the pool accepted or rejected
+func (g *GpuGoroutine) CountShare(accepted bool) {
+	WorkersMu.Lock()
+	defer WorkersMu.Unlock()
+
+	if accepted {
+		g.Accepted++
+	} else {
+		g.Rejected++
 	}
 }
 
-func searchGpusWithRegexCuda(execStr string) ([]GPUstruct, error) {
-	cmd := exec.Command(execStr)
-
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
+// Runnable reports whether the miner of the gpu may be started
+func (g *GpuGoroutine) Runnable() bool {
+	return !g.Retired && !g.Paused && !g.Draining && g.Thermal != ThermalPaused
+}
 
-	if err := cmd.Start(); err != nil {
-		mlog.LogFatalStackError(err)
+// State sums up the worker for the control api
+func (g *GpuGoroutine) State() string {
+	switch {
+	case g.Retired:
+		return StateRetired
+	case g.Paused:
+		return StatePaused
+	case g.Draining && g.Running():
+		return StateDraining
+	case g.Draining:
+		return StateDrained
+	case g.Thermal == ThermalPaused:
+		return StateThermalPaused
+	case g.Thermal == ThermalThrottled:
+		return StateThrottled
+	case g.Running():
+		return StateRunning
 	}
-	cmd.Wait()
+	return StateIdle
+}
 
-	mlog.LogInfo("CUDA Info: " + stderr.String())
+// Running tells whether the miner process is running
+func (g *GpuGoroutine) Running() bool {
+	g.procMu.Lock()
+	defer g.procMu.Unlock()
+	return g.running
+}
 
-	matches := config.MRgxKit.FindGPUPat.FindAllString(stderr.String(), -1)
+// RunningTask returns the pool task of the running miner, false if none
+func (g *GpuGoroutine) RunningTask() (int, bool) {
+	g.procMu.Lock()
+	defer g.procMu.Unlock()
+	return g.TaskId, g.running
+}
 
-	var gpusArray []GPUstruct
+// Output returns what the last started miner wrote to stderr
+func (g *GpuGoroutine) Output() string {
+	g.procMu.Lock()
+	output := g.output
+	g.procMu.Unlock()
+	if output == nil {
+		return ""
+	}
+	return output.String()
+}
 
-	for _, v := range matches {
-		gpuModel := strings.TrimSpace(
-			config.MRgxKit.ReplaceEndGPU.ReplaceAllString(config.MRgxKit.ReplaceStartGPU.ReplaceAllString(v, ""), ""),
-		)
+// KeepAlive tells whether the next task is started when the miner exits
+func (g *GpuGoroutine) KeepAlive() bool {
+	g.procMu.Lock()
+	defer g.procMu.Unlock()
+	return g.keepAlive
+}
 
-		// skip for integrated intel GPU
-		if strings.Contains(strings.ToLower(gpuModel), "intel") {
-			continue
-		}
+// StartMiner starts cmd as the miner of the gpu for the task and returns
+// its stderr. It returns nil without starting it if a miner is already
+// running or the gpu is not Runnable, e.g. when a resume and the end of the
+// previous task both want to start one. The output of a running miner is
+// left alone then.
+func (g *GpuGoroutine) StartMiner(cmd *exec.Cmd, taskId int) (*MinerOutput, error) {
+	WorkersMu.RLock()
+	defer WorkersMu.RUnlock()
+	g.procMu.Lock()
+	defer g.procMu.Unlock()
+
+	if g.running || !g.Runnable() {
+		return nil, nil
+	}
+	output := &MinerOutput{}
+	cmd.Stderr = output
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	g.PPid, g.TaskId, g.output = cmd.Process.Pid, taskId, output
+	g.running, g.stopped, g.keepAlive = true, false, true
+	return output, nil
+}
 
-		panddId := config.MRgxKit.FindIntIds.FindAllString(v, -1)
-		if len(panddId) < 1 {
-			mlog.LogInfo("warn: can't search GPU - len(panddId) < 2; panddId: " + strings.Join(panddId, ", ") + "; v: " + v)
-			continue
-		}
+// MinerExited records the end of the miner process and tells whether it
+// was killed by StopMiner
+func (g *GpuGoroutine) MinerExited() (stopped bool) {
+	g.procMu.Lock()
+	defer g.procMu.Unlock()
+	g.running = false
+	return g.stopped
+}
 
-		// platformId, err := strconv.Atoi(strings.Replace(panddId[0], "#", "", -1))
-		// if err != nil {
-		// 	return gpusArray, errors.New("can't get platformId: " + err.Error())
-		// }
-		deviceId, err := strconv.Atoi(strings.Replace(panddId[0], "#", "", -1))
-		if err != nil {
-			return gpusArray, errors.New("can't get deviceId: " + err.Error())
+// BoostFactor is the -F value for the next miner start
+func (g *GpuGoroutine) BoostFactor() int {
+	if g.Thermal == ThermalThrottled {
+		config.Mu.RLock()
+		throttle := config.Thermal.Throttle
+		config.Mu.RUnlock()
+		if throttle > 0 { // unless a reload removed -throttle-boost
+			return throttle
 		}
-
-		gpusArray = append(
-			gpusArray,
-			GPUstruct{
-				GpuId:      deviceId,
-				Model:      gpuModel,
-				PlatformId: 0,
-			},
-		)
 	}
-
-	// if len(gpusArray) < 1 {
-	// 	return gpusArray, errors.New("no any GPUs found")
-	// }
-
-	return gpusArray, nil
+	return config.StaticBeforeMinerSettings.BoostFactor
 }
 
-func SearchGpusCuda(execStr string) []GPUstruct {
-	nvidiaGpus, err := searchGpusWithRegexCuda(execStr)
+// StopMiner kills the running miner process. It is started again with the
+// next task if KeepAlive and the gpu is Runnable.
+func (g *GpuGoroutine) StopMiner() {
+	g.procMu.Lock()
+	defer g.procMu.Unlock()
+
+	if g.PPid == 0 || !g.running {
+		return
+	}
+	proc, err := os.FindProcess(g.PPid)
 	if err != nil {
-		mlog.LogFatal(err.Error())
+		mlog.LogInfo("warning: FindProcess: " + err.Error())
+		return
+	}
+	g.stopped = true
+	if err := proc.Kill(); err != nil {
+		mlog.LogInfo("warning: proc.Kill: " + err.Error())
 	}
-	return nvidiaGpus
 }
 
-func searchGpusWithRegexOpenCL(execStr string) ([]GPUstruct, error) {
-	cmd := exec.Command(execStr)
-
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
+func LogGpuList(gpus []GPUstruct) {
+	var gpuNames []string
 
-	if err := cmd.Start(); err != nil {
-		mlog.LogFatalStackError(err)
+	for i := 0; i < len(gpus); i++ {
+		gpuNames = append(gpuNames, gpus[i].Model)
 	}
-	cmd.Wait()
-
-	mlog.LogInfo("OpenCL Info: " + stderr.String())
 
-	matches := config.MRgxKit.FindGPUPat.FindAllString(stderr.String(), -1)
-
-	var gpusArray []GPUstruct
+	dict := make(map[string]int)
+	for _, num := range gpuNames {
+		dict[num] = dict[num] + 1
+	}
 
-	for _, v := range matches {
-		gpuModel := strings.TrimSpace(
-			config.MRgxKit.ReplaceEndGPU.ReplaceAllString(config.MRgxKit.ReplaceStartGPU.ReplaceAllString(v, ""), ""),
-		)
+	mlog.LogInfo("Found GPUs:")
+	for model, count := range dict {
+		mlog.LogInfo("x" + strconv.Itoa(count) + " " + model + "\n")
+	}
 
-		// skip for integrated intel GPU
-		if strings.Contains(strings.ToLower(gpuModel), "intel") {
-			continue
+	for i, gpu := range gpus {
+		location := gpu.Backend
+		if gpu.BusId != "" {
+			location += ", PCI " + gpu.BusId
 		}
+		mlog.LogInfo("gpu " + strconv.Itoa(i) + ": " + gpu.Model + " (" + location + ")")
+	}
+}
 
-		amdApuList := []string{
-			"gfx700",
-			"gfx703",
-			"gfx705",
-			"gfx801",
-			"gfx810",
-			"gfx902",
-			"gfx909",
-			"gfx90c",
-			"gfx1013",
-			"gfx1033",
-			"gfx1035",
-			"gfx1036",
-			"gfx1103",
-			"gfx1150",
-			"gfx1151",
-		}
-		amdApuFound := false
-		for _, amgGpu := range amdApuList {
-			if strings.Contains(strings.ToLower(gpuModel), amgGpu) {
-				amdApuFound = true
-				break
-			}
-		}
-		if amdApuFound {
+// KillAll stops the miner processes of all gpus and keeps them from being
+// restarted
+func KillAll(gpus *[]*GpuGoroutine) {
+	WorkersMu.RLock()
+	defer WorkersMu.RUnlock()
+
+	for i := 0; i < len(*gpus); i++ {
+		(*gpus)[i].procMu.Lock()
+		(*gpus)[i].keepAlive = false
+		pPid := (*gpus)[i].PPid
+		(*gpus)[i].procMu.Unlock()
+		gpuModel := (*gpus)[i].GpuData.Model
+		gpuId := (*gpus)[i].GpuData.GpuId
+		proc, err := os.FindProcess(pPid)
+		if err != nil {
+			mlog.LogInfo("warning: FindProcess: " + err.Error())
 			continue
 		}
 
-		panddId := config.MRgxKit.FindIntIds.FindAllString(v, -1)
-		if len(panddId) < 2 {
-			mlog.LogInfo("warn: can't search GPU - len(panddId) < 2; panddId: " + strings.Join(panddId, ", ") + "; v: " + v)
+		if err := proc.Kill(); err != nil {
+			mlog.LogInfo("warning: proc.Kill: " + err.Error())
 			continue
 		}
 
-		platformId, err := strconv.Atoi(strings.Replace(panddId[0], "#", "", -1))
-		if err != nil {
-			return gpusArray, errors.New("can't get platformId: " + err.Error())
-		}
-		deviceId, err := strconv.Atoi(strings.Replace(panddId[1], "#", "", -1))
-		if err != nil {
-			return gpusArray, errors.New("can't get deviceId: " + err.Error())
-		}
-
-		gpusArray = append(
-			gpusArray,
-			GPUstruct{
-				GpuId:      deviceId,
-				Model:      gpuModel,
-				PlatformId: platformId,
-			},
-		)
-	}
-
-	// if len(gpusArray) < 1 {
-	// 	return gpusArray, errors.New("no any GPUs found")
-	// }
-
-	return gpusArray, nil
-}
-
-func SearchGpusOpenCL(execStr string) []GPUstruct {
-	nvidiaGpus, err := searchGpusWithRegexOpenCL(execStr)
-	if err != nil {
-		mlog.LogFatal(err.Error())
+		mlog.LogOk(fmt.Sprintf(
+			"%s (gpuId: %s; pid: %s) - killed",
+			gpuModel,
+			strconv.Itoa(gpuId),
+			strconv.Itoa(pPid),
+		))
 	}
-	return nvidiaGpus
 }
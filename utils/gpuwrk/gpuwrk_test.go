@@ -0,0 +1,95 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package gpuwrk
+
+import (
+	"os/exec"
+	"testing"
+)
+
+func TestStartMinerOnce(t *testing.T) {
+	g := &GpuGoroutine{}
+
+	first := exec.Command("sleep", "10")
+	output, err := g.StartMiner(first, 1)
+	if err != nil || output == nil {
+		t.Fatalf("StartMiner = %v, %v, want the miner started", output, err)
+	}
+	output.Write([]byte("first miner\n"))
+	if taskId, running := g.RunningTask(); !running || taskId != 1 {
+		t.Fatalf("RunningTask = %d, %v, want 1, true", taskId, running)
+	}
+
+	// e.g. a resume while the previous task's miner is still running
+	second := exec.Command("sleep", "10")
+	if output, err := g.StartMiner(second, 2); err != nil || output != nil {
+		t.Fatalf("second StartMiner = %v, %v, want it refused", output, err)
+	}
+	if second.Process != nil {
+		t.Fatal("second miner process was started")
+	}
+	if got := g.Output(); got != "first miner\n" {
+		t.Errorf("Output = %q after a refused start, want the running miner's", got)
+	}
+
+	g.StopMiner()
+	first.Wait()
+	if stopped := g.MinerExited(); !stopped {
+		t.Error("MinerExited after StopMiner reports it was not stopped")
+	}
+	if g.Running() {
+		t.Error("still running after MinerExited")
+	}
+
+	g.Paused = true
+	if output, _ := g.StartMiner(exec.Command("sleep", "10"), 3); output != nil {
+		t.Error("started the miner of a paused gpu")
+	}
+}
+
+func TestTelemetryKey(t *testing.T) {
+	withBus := GPUstruct{GpuId: 1, Backend: BackendCuda, BusId: "01:00.0"}
+	cuda := GPUstruct{GpuId: 1, Backend: BackendCuda}
+	openCL := GPUstruct{GpuId: 1, Backend: BackendOpenCL}
+
+	tests := []struct {
+		gpu     GPUstruct
+		order   string
+		visible string
+		want    string
+	}{
+		{withBus, "", "", "0000:01:00.0"},
+		{cuda, "PCI_BUS_ID", "", "nvidia:1"},
+		{cuda, "FASTEST_FIRST", "", ""},
+		{cuda, "PCI_BUS_ID", "1", ""}, // device ids no longer match the nvidia-smi index
+		{openCL, "PCI_BUS_ID", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("CUDA_DEVICE_ORDER", tt.order)
+		t.Setenv("CUDA_VISIBLE_DEVICES", tt.visible)
+		if got := TelemetryKey(tt.gpu); got != tt.want {
+			t.Errorf("TelemetryKey(%+v) with CUDA_DEVICE_ORDER=%q CUDA_VISIBLE_DEVICES=%q = %q, want %q",
+				tt.gpu, tt.order, tt.visible, got, tt.want)
+		}
+	}
+}
@@ -1,58 +1,107 @@
 package gpuwrk
 
 import (
-	"encoding/json"
-	"io/ioutil"
 	"miningPoolCli/config"
 	"miningPoolCli/utils/mlog"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
-func CalcHashrate(gpus *[]GpuGoroutine) {
-	var genStats struct {
-		Khs    int   `json:"khs"`    // khs | total hashrate
-		Uptime int64 `json:"uptime"` // uptime
-		Hs     []int `json:"hs"`     // hs | array of hashrates
-	}
+// Stats of the miners, one entry per gpu in the arrays. It is written to
+// the data directory with -stats, see export.StatsFileName.
+type Stats struct {
+	Khs      int      `json:"khs"`     // khs | total hashrate
+	Uptime   int64    `json:"uptime"`  // uptime
+	Hs       []int    `json:"hs"`      // hs | array of hashrates
+	Temp     []int    `json:"temp"`    // °C, 0 if unknown
+	Fan      []int    `json:"fan"`     // %
+	Power    []int    `json:"power"`   // W
+	BusIds   []string `json:"bus_ids"` // PCI bus id, "" if unknown
+	Models   []string `json:"models"`
+	Accepted []int    `json:"accepted"` // shares
+	Rejected []int    `json:"rejected"`
+	Updated  int64    `json:"updated"` // unix time of the write
+}
+
+func (s *Stats) appendGpu(g *GpuGoroutine) {
+	s.Khs += g.CurrentHashrate
+	s.Hs = append(s.Hs, g.CurrentHashrate)
+	s.Temp = append(s.Temp, g.Telemetry.Temp)
+	s.Fan = append(s.Fan, g.Telemetry.Fan)
+	s.Power = append(s.Power, g.Telemetry.Power)
+	s.BusIds = append(s.BusIds, g.GpuData.BusId)
+	s.Models = append(s.Models, g.GpuData.Model)
+	s.Accepted = append(s.Accepted, g.Accepted)
+	s.Rejected = append(s.Rejected, g.Rejected)
+}
+
+var latestStats struct {
+	sync.Mutex
+	stats Stats
+	ok    bool
+}
+
+// LatestStats returns the stats of the last CalcHashrate that got a
+// hashrate from every gpu
+func LatestStats() (Stats, bool) {
+	latestStats.Lock()
+	defer latestStats.Unlock()
+	return latestStats.stats, latestStats.ok
+}
+
+// CalcHashrate reads the hashrates from the miners' output. It returns false
+// until every miner reported one. It takes WorkersMu for writing, the
+// hashrates are read by the stat server, the TUI and the history.
+func CalcHashrate(gpus *[]*GpuGoroutine) (Stats, bool) {
+	WorkersMu.Lock()
+	defer WorkersMu.Unlock()
+
+	var genStats Stats
 
 	for i, v := range *gpus {
-		hsArr := config.MRgxKit.FindHashRate.FindAllString(v.ProcStderr.String(), -1)
+		if !v.Running() && !v.Runnable() {
+			// paused, its output is from the last run
+			v.CurrentHashrate = 0
+			genStats.appendGpu(v)
+			continue
+		}
+
+		hsArr := config.MRgxKit.FindHashRate.FindAllString(v.Output(), -1)
 		if len(hsArr) < 2 {
-			return
+			return genStats, false
 		}
 
 		hS := config.MRgxKit.FindDecimal.FindAllString(hsArr[len(hsArr)-1], -1)
 		if len(hS) < 1 {
-			return
+			return genStats, false
 		}
 
 		sep := strings.Split(hS[0], ".")
 		if len(sep) != 2 {
-			return
+			return genStats, false
 		}
 
 		perHashRate, err := strconv.Atoi(sep[0])
 		if err != nil {
-			return
+			return genStats, false
 		}
 
 		(*gpus)[i].CurrentHashrate = perHashRate
+		genStats.appendGpu(v)
+	}
 
-		genStats.Khs += perHashRate
-		genStats.Hs = append(genStats.Hs, perHashRate)
+	genStats.Updated = time.Now().Unix()
+	genStats.Uptime = genStats.Updated - config.StartProgramTimestamp
 
-	}
+	latestStats.Lock()
+	latestStats.stats, latestStats.ok = genStats, true
+	latestStats.Unlock()
 
-	if config.UpdateStatsFile {
-		genStats.Uptime = time.Now().Unix() - config.StartProgramTimestamp
-		file, err := json.Marshal(genStats)
-		if err != nil {
-			mlog.LogFatalStackError(err)
-		}
-		_ = ioutil.WriteFile("stats.json", file, 0644)
+	if !config.TUI {
+		// shown in the table instead
+		mlog.LogInfo("Total hashrate: ~" + strconv.Itoa(genStats.Khs) + " Mh")
 	}
-
-	mlog.LogInfo("Total hashrate: ~" + strconv.Itoa(genStats.Khs) + " Mh")
+	return genStats, true
 }
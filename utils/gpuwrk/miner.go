@@ -0,0 +1,151 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package gpuwrk
+
+import (
+	"errors"
+	"miningPoolCli/config"
+	"miningPoolCli/utils/helpers"
+	"miningPoolCli/utils/mlog"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+// MinerCommand builds the miner process for the gpu. The device and static
+// settings go first, then the user's extra args, then the positional
+// task arguments (pool address, seed, complexity, iterations).
+func MinerCommand(gpu GPUstruct, boostFactor int, positional ...string) *exec.Cmd {
+	minerArgs := []string{
+		// "-vv",
+		// "-V",
+		// "-B",
+		"-g" + strconv.Itoa(gpu.GpuId),
+		"-p" + strconv.Itoa(gpu.PlatformId),
+		"-F" + strconv.Itoa(boostFactor),
+		"-t" + strconv.Itoa(config.StaticBeforeMinerSettings.TimeoutT),
+	}
+	minerArgs = append(minerArgs, gpu.ExtraArgs...)
+	minerArgs = append(minerArgs, positional...)
+
+	cmd := exec.Command(gpu.StartPath, minerArgs...)
+	if len(gpu.Env) > 0 {
+		cmd.Env = append(os.Environ(), gpu.Env...)
+	}
+
+	return cmd
+}
+
+// gpuOverride holds the -gpu-args, -gpu-env and -gpu-max-temp of one gpu
+type gpuOverride struct {
+	args, env []string
+	maxTemp   int
+}
+
+// gpuOverrides are resolved once by ResolveGpuOverrides and keyed by Key, so
+// a rediscovered list where a gpu disappeared doesn't shift them to its
+// neighbours
+var gpuOverrides = map[string]gpuOverride{}
+
+// ResolveGpuOverrides maps the gpu indexes of the -gpu-* flags to the gpus,
+// the index is the position in gpus. It also checks the -miner-args* flags.
+// Like discovery it must not run during a reload.
+func ResolveGpuOverrides(gpus []GPUstruct) error {
+	config.Mu.RLock()
+	defer config.Mu.RUnlock()
+
+	if err := config.MinerOverrides.Check(); err != nil {
+		return err
+	}
+	if err := config.Thermal.Check(); err != nil {
+		return err
+	}
+
+	overrides := map[string]gpuOverride{}
+	for i, gpu := range gpus {
+		var o gpuOverride
+		for _, gpuArgs := range config.MinerOverrides.GpuArgs[i] {
+			args, _ := helpers.SplitArgs(gpuArgs)
+			o.args = append(o.args, args...)
+		}
+		o.env = config.MinerOverrides.GpuEnv[i]
+		o.maxTemp, _ = config.Thermal.GpuMaxTempOf(i)
+		if o.maxTemp > 0 && TelemetryKey(gpu) == "" {
+			return errors.New("-gpu-max-temp for gpu " + strconv.Itoa(i) + " can't apply, there is no telemetry for " +
+				gpuName(gpu) + " (unknown PCI bus id)")
+		}
+		overrides[Key(gpu)] = o
+	}
+
+	for _, flag := range []struct {
+		name    string
+		indexed helpers.IndexedFlag
+	}{
+		{"-gpu-args", config.MinerOverrides.GpuArgs},
+		{"-gpu-env", config.MinerOverrides.GpuEnv},
+		{"-gpu-max-temp", config.Thermal.GpuMaxTemp},
+	} {
+		for i := range flag.indexed {
+			if i >= len(gpus) {
+				mlog.LogInfo("warn: " + flag.name + " for gpu " + strconv.Itoa(i) + " ignored, only " + strconv.Itoa(len(gpus)) + " GPUs found")
+			}
+		}
+	}
+
+	gpuOverrides = overrides
+	return nil
+}
+
+// ApplyMinerOverrides sets the extra args, environment and max temperature
+// of every gpu from the -miner-args* flags and the overrides resolved by
+// ResolveGpuOverrides. Gpus found later get the -miner-args* only.
+func ApplyMinerOverrides(gpus []GPUstruct) {
+	config.Mu.RLock()
+	defer config.Mu.RUnlock()
+
+	common := mustSplitArgs("-miner-args", config.MinerOverrides.Args)
+	perBackend := map[string][]string{
+		BackendCuda:   mustSplitArgs("-miner-args-cuda", config.MinerOverrides.ArgsCuda),
+		BackendOpenCL: mustSplitArgs("-miner-args-opencl", config.MinerOverrides.ArgsOpenCL),
+	}
+
+	for i := range gpus {
+		o := gpuOverrides[Key(gpus[i])]
+
+		var args []string
+		args = append(args, common...)
+		args = append(args, perBackend[gpus[i].Backend]...)
+		args = append(args, o.args...)
+
+		gpus[i].ExtraArgs = args
+		gpus[i].Env = o.env
+		gpus[i].MaxTemp = o.maxTemp
+	}
+}
+
+func mustSplitArgs(flagName, s string) []string {
+	args, err := helpers.SplitArgs(s)
+	if err != nil {
+		mlog.LogFatal("invalid " + flagName + ": " + err.Error())
+	}
+	return args
+}
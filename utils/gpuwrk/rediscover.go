@@ -0,0 +1,101 @@
+package gpuwrk
+
+import (
+	"miningPoolCli/utils/mlog"
+	"miningPoolCli/utils/pci"
+	"sync"
+)
+
+// WorkersMu guards the list of workers, which changes on rediscovery
+var WorkersMu sync.RWMutex
+
+// Key identifies a gpu across discoveries: the PCI bus id is stable while
+// the device ids can change when a card is added or removed
+func Key(gpu GPUstruct) string {
+	if gpu.BusId != "" {
+		return gpu.Backend + "@" + pci.NormalizeBusId(gpu.BusId)
+	}
+	return gpuName(gpu)
+}
+
+type RediscoverResult struct {
+	Started   []*GpuGoroutine // new gpus, the miner has to be started
+	Restarted []*GpuGoroutine // the gpu changed, the running miner has to be killed
+	Retired   []*GpuGoroutine // the gpu is gone, the running miner has to be killed
+}
+
+// MergeWorkers matches the workers against the freshly discovered gpus and
+// returns the new list of workers. Retired workers have keepAlive cleared so
+// their miner is not started again.
+func MergeWorkers(workers []*GpuGoroutine, gpus []GPUstruct) ([]*GpuGoroutine, RediscoverResult) {
+	var result RediscoverResult
+
+	found := make(map[string]GPUstruct, len(gpus))
+	for _, gpu := range gpus {
+		found[Key(gpu)] = gpu
+	}
+
+	var merged []*GpuGoroutine
+	known := make(map[string]bool, len(workers))
+	for _, w := range workers {
+		key := Key(w.GpuData)
+		gpu, ok := found[key]
+		if !ok {
+			w.Retired = true
+			w.procMu.Lock()
+			w.keepAlive = false
+			w.procMu.Unlock()
+			result.Retired = append(result.Retired, w)
+			continue
+		}
+
+		known[key] = true
+		w.GpuData.MaxTemp = gpu.MaxTemp // no restart needed
+		if changed(w.GpuData, gpu) {
+			w.GpuData = gpu
+			result.Restarted = append(result.Restarted, w)
+		}
+		merged = append(merged, w)
+	}
+
+	for _, gpu := range gpus {
+		if known[Key(gpu)] {
+			continue
+		}
+		w := &GpuGoroutine{GpuData: gpu}
+		merged = append(merged, w)
+		result.Started = append(result.Started, w)
+	}
+
+	return merged, result
+}
+
+func changed(a, b GPUstruct) bool {
+	if a.GpuId != b.GpuId || a.PlatformId != b.PlatformId || a.Model != b.Model ||
+		a.StartPath != b.StartPath || len(a.ExtraArgs) != len(b.ExtraArgs) || len(a.Env) != len(b.Env) {
+		return true
+	}
+	for i := range a.ExtraArgs {
+		if a.ExtraArgs[i] != b.ExtraArgs[i] {
+			return true
+		}
+	}
+	for i := range a.Env {
+		if a.Env[i] != b.Env[i] {
+			return true
+		}
+	}
+	return false
+}
+
+func (r RediscoverResult) Log() {
+	for _, w := range r.Started {
+		mlog.LogOk("New GPU found, starting miner: " + w.GpuData.Model + " (" + Key(w.GpuData) + ")")
+	}
+	for _, w := range r.Restarted {
+		mlog.LogInfo("GPU changed, restarting miner: " + w.GpuData.Model + " (" + Key(w.GpuData) + ")")
+	}
+	for _, w := range r.Retired {
+		mlog.LogError("GPU disappeared, stopping miner: " + w.GpuData.Model + " (" + Key(w.GpuData) + ")")
+	}
+}
@@ -0,0 +1,84 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package gpuwrk
+
+import (
+	"miningPoolCli/config"
+	"miningPoolCli/utils/helpers"
+	"testing"
+)
+
+func TestRediscoverKeepsOverrides(t *testing.T) {
+	config.Configure()
+	config.MinerOverrides.GpuArgs = helpers.IndexedFlag{1: {"-F 256"}}
+	config.Thermal.GpuMaxTemp = helpers.IndexedFlag{1: {"70"}}
+
+	a := GPUstruct{GpuId: 0, Model: "RX 580", Backend: BackendOpenCL, BusId: "0000:01:00.0"}
+	b := GPUstruct{GpuId: 1, Model: "RX 580", Backend: BackendOpenCL, BusId: "0000:02:00.0"}
+	c := GPUstruct{GpuId: 2, Model: "RX 580", Backend: BackendOpenCL, BusId: "0000:03:00.0"}
+
+	gpus := []GPUstruct{a, b, c}
+	if err := ResolveGpuOverrides(gpus); err != nil {
+		t.Fatal(err)
+	}
+	ApplyMinerOverrides(gpus)
+	if len(gpus[1].ExtraArgs) != 2 || gpus[1].MaxTemp != 70 || len(gpus[2].ExtraArgs) != 0 {
+		t.Fatalf("overrides not applied by index: %+v", gpus)
+	}
+
+	var workers []*GpuGoroutine
+	for _, gpu := range gpus {
+		workers = append(workers, &GpuGoroutine{GpuData: gpu})
+	}
+
+	// b is gone and c moved to index 1, c must not get b's overrides
+	c.GpuId = 1
+	found := []GPUstruct{a, c}
+	ApplyMinerOverrides(found)
+	if len(found[1].ExtraArgs) != 0 || found[1].MaxTemp != 0 {
+		t.Fatalf("c got the overrides of b: %+v", found[1])
+	}
+
+	merged, result := MergeWorkers(workers, found)
+	if len(merged) != 2 || len(result.Retired) != 1 || result.Retired[0].GpuData.BusId != b.BusId {
+		t.Fatalf("merged %d, retired %+v", len(merged), result.Retired)
+	}
+	// the device id of c changed, a is untouched
+	if len(result.Restarted) != 1 || result.Restarted[0].GpuData.BusId != c.BusId {
+		t.Fatalf("restarted %+v", result.Restarted)
+	}
+
+	// the same gpus again: nothing changes
+	again := []GPUstruct{a, c}
+	ApplyMinerOverrides(again)
+	if _, result := MergeWorkers(merged, again); len(result.Restarted)+len(result.Started)+len(result.Retired) != 0 {
+		t.Fatalf("healthy gpus touched: %+v", result)
+	}
+}
+
+func TestResolveGpuOverridesInvalid(t *testing.T) {
+	config.Configure()
+	config.Thermal.GpuMaxTemp = helpers.IndexedFlag{0: {"hot"}}
+	if err := ResolveGpuOverrides([]GPUstruct{{Backend: BackendCuda}}); err == nil {
+		t.Fatal("invalid -gpu-max-temp accepted")
+	}
+}
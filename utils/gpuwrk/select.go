@@ -0,0 +1,147 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package gpuwrk
+
+import (
+	"errors"
+	"miningPoolCli/config"
+	"miningPoolCli/utils/pci"
+	"strconv"
+	"strings"
+)
+
+// MatchGpu reports whether the gpu matches a -gpus / -exclude-gpus token:
+//
+//	#1           device id of the miner on any backend
+//	cuda:1       device id on a backend
+//	opencl:0:1   platform and device id
+//	01:00.0      PCI bus id, the domain is optional
+//	rx 580       model substring, case insensitive
+//
+// The device ids are the miner's, not the positional index of -gpu-args.
+// Bare numbers match nothing, CheckGpuTokens rejects them.
+func MatchGpu(gpu GPUstruct, token string) bool {
+	token = strings.TrimSpace(token)
+	if token == "" || isBareNumber(token) {
+		return false
+	}
+
+	if strings.HasPrefix(token, "#") {
+		id, err := strconv.Atoi(token[1:])
+		return err == nil && gpu.GpuId == id
+	}
+
+	if parts := strings.Split(strings.ToLower(token), ":"); parts[0] == BackendCuda || parts[0] == BackendOpenCL {
+		if parts[0] != gpu.Backend {
+			return false
+		}
+		switch len(parts) {
+		case 2:
+			return parts[1] == strconv.Itoa(gpu.GpuId)
+		case 3:
+			return parts[1] == strconv.Itoa(gpu.PlatformId) && parts[2] == strconv.Itoa(gpu.GpuId)
+		}
+		return false
+	}
+
+	if busId := pci.NormalizeBusId(token); busId != "" {
+		return gpu.BusId == busId
+	}
+
+	return strings.Contains(strings.ToLower(gpu.Model), strings.ToLower(token))
+}
+
+func isBareNumber(token string) bool {
+	_, err := strconv.Atoi(token)
+	return err == nil
+}
+
+// CheckGpuTokens validates -gpus / -exclude-gpus. A bare number could be a
+// device id or a part of the model name, it has to be written as one of them.
+func CheckGpuTokens(tokens []string) error {
+	for _, token := range tokens {
+		if token = strings.TrimSpace(token); isBareNumber(token) {
+			return errors.New("ambiguous GPU \"" + token + "\" in -gpus / -exclude-gpus, use \"#" + token +
+				"\" for the device id or more of the model name (\"rtx 3060\")")
+		}
+	}
+	return nil
+}
+
+func matchAny(gpu GPUstruct, tokens []string) (string, bool) {
+	for _, token := range tokens {
+		if MatchGpu(gpu, token) {
+			return token, true
+		}
+	}
+	return "", false
+}
+
+// IsIntegrated reports whether the model is in the integrated GPU / APU list
+func IsIntegrated(model string) (string, bool) {
+	model = strings.ToLower(model)
+	for _, name := range config.GpuSelect.IntegratedModels {
+		if name != "" && strings.Contains(model, strings.ToLower(name)) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// SelectGpus sets Excluded on every gpu that is not used according to
+// -gpus, -exclude-gpus and the integrated GPU list. Already excluded gpus
+// are left alone.
+func SelectGpus(gpus []GPUstruct) {
+	for i := range gpus {
+		gpu := &gpus[i]
+		if gpu.Excluded != "" {
+			continue
+		}
+
+		if len(config.GpuSelect.Include) > 0 {
+			if _, ok := matchAny(*gpu, config.GpuSelect.Include); !ok {
+				gpu.Excluded = "not in -gpus"
+				continue
+			}
+		}
+		if token, ok := matchAny(*gpu, config.GpuSelect.Exclude); ok {
+			gpu.Excluded = "matches -exclude-gpus \"" + token + "\""
+			continue
+		}
+		if !config.GpuSelect.IncludeIntegrated {
+			if name, ok := IsIntegrated(gpu.Model); ok {
+				gpu.Excluded = "integrated GPU (\"" + name + "\"), use -include-integrated to mine on it"
+			}
+		}
+	}
+}
+
+// Selected returns the gpus that are not excluded
+func Selected(gpus []GPUstruct) []GPUstruct {
+	var selected []GPUstruct
+	for _, gpu := range gpus {
+		if gpu.Excluded == "" {
+			selected = append(selected, gpu)
+		}
+	}
+	return selected
+}
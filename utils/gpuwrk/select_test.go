@@ -0,0 +1,80 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package gpuwrk
+
+import "testing"
+
+func TestMatchGpu(t *testing.T) {
+	rtx := GPUstruct{GpuId: 1, Model: "NVIDIA GeForce RTX 3060", Backend: BackendCuda, BusId: "0000:03:00.0"}
+	rx := GPUstruct{GpuId: 0, PlatformId: 1, Model: "AMD Radeon RX 580", Backend: BackendOpenCL}
+
+	tests := []struct {
+		gpu   GPUstruct
+		token string
+		want  bool
+	}{
+		{rtx, "3060", false}, // bare numbers are ambiguous
+		{rtx, "1", false},
+		{rtx, "rtx 3060", true},
+		{rtx, "#1", true},
+		{rx, "#1", false},
+		{rx, "2", false},
+		{rx, "#0", true},
+		{rtx, "cuda:1", true},
+		{rtx, "opencl:1", false},
+		{rx, "opencl:1:0", true},
+		{rx, "opencl:0:0", false},
+		{rtx, "03:00.0", true},
+		{rtx, "04:00.0", false},
+		{rx, "rx 580", true},
+		{rx, " RX 580 ", true},
+		{rx, "580", false},
+		{rx, "0", false},
+		{rx, "", false},
+		{rx, "#x", false},
+	}
+
+	for _, tt := range tests {
+		if got := MatchGpu(tt.gpu, tt.token); got != tt.want {
+			t.Errorf("MatchGpu(%s, %q) = %v, want %v", tt.gpu.Model, tt.token, got, tt.want)
+		}
+	}
+}
+
+func TestCheckGpuTokens(t *testing.T) {
+	tests := []struct {
+		tokens []string
+		ok     bool
+	}{
+		{nil, true},
+		{[]string{"#0", "cuda:1", "opencl:0:1", "01:00.0", "rtx 3060"}, true},
+		{[]string{"0"}, false},
+		{[]string{"#1", " 1 "}, false},
+		{[]string{"1080"}, false},
+	}
+
+	for _, tt := range tests {
+		if err := CheckGpuTokens(tt.tokens); (err == nil) != tt.ok {
+			t.Errorf("CheckGpuTokens(%q) = %v, want ok %v", tt.tokens, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package gpuwrk
+
+import (
+	"miningPoolCli/config"
+	"miningPoolCli/utils/mlog"
+	"miningPoolCli/utils/pci"
+	"miningPoolCli/utils/telemetry"
+	"os"
+	"strconv"
+	"time"
+)
+
+func NewCollector() telemetry.Collector {
+	return telemetry.Collector{
+		NvidiaSmi: config.Telemetry.NvidiaSmi,
+		RocmSmi:   config.Telemetry.RocmSmi,
+		SysRoot:   config.Paths.SysfsRoot,
+	}
+}
+
+// FormatSensor formats a telemetry value for the tables, 0 means unknown
+func FormatSensor(v int, unit string) string {
+	if v == 0 {
+		return "-"
+	}
+	return strconv.Itoa(v) + unit
+}
+
+// TelemetryKey is the key of the gpu's readings in telemetry.Collect: the
+// PCI bus id, or for a CUDA gpu without one its nvidia-smi index, which is
+// the device id when CUDA numbers the devices in PCI order as well. It's ""
+// if the gpu can't be matched to a reading.
+func TelemetryKey(gpu GPUstruct) string {
+	if busId := pci.NormalizeBusId(gpu.BusId); busId != "" {
+		return busId
+	}
+	if gpu.Backend == BackendCuda && os.Getenv("CUDA_DEVICE_ORDER") == "PCI_BUS_ID" &&
+		os.Getenv("CUDA_VISIBLE_DEVICES") == "" {
+		return telemetry.NvidiaIndexKey(gpu.GpuId)
+	}
+	return ""
+}
+
+// PollTelemetry updates the sensor readings of the workers, matched by
+// TelemetryKey, every config.Telemetry.Interval, and calls updated after
+// each round. A failing source is logged once, as is a gpu that can't be
+// matched and gets no readings.
+func PollTelemetry(gpus *[]*GpuGoroutine, updated func()) {
+	collector := NewCollector()
+	reported := map[string]bool{}
+	unmatched := map[string]bool{}
+
+	for {
+		readings, errs := collector.Collect()
+		for _, err := range errs {
+			if !reported[err.Error()] {
+				reported[err.Error()] = true
+				mlog.LogError("Telemetry: " + err.Error())
+			}
+		}
+
+		WorkersMu.Lock()
+		for _, g := range *gpus {
+			key := TelemetryKey(g.GpuData)
+			if key == "" && !unmatched[Key(g.GpuData)] {
+				unmatched[Key(g.GpuData)] = true
+				mlog.LogError("Telemetry: no PCI bus id for " + gpuName(g.GpuData) +
+					", its temperature, fan and power are not shown")
+			}
+			g.Telemetry = readings[key]
+		}
+		WorkersMu.Unlock()
+
+		if updated != nil {
+			updated()
+		}
+
+		time.Sleep(config.Telemetry.Interval)
+	}
+}
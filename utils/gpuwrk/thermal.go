@@ -0,0 +1,141 @@
+package gpuwrk
+
+import (
+	"fmt"
+	"miningPoolCli/config"
+	"miningPoolCli/utils/mlog"
+	"miningPoolCli/utils/telemetry"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+const (
+	ThermalOk        = ""
+	ThermalPaused    = "paused"
+	ThermalThrottled = "throttled"
+)
+
+// ThermalChange is a gpu whose guard state changed from From to g.Thermal
+type ThermalChange struct {
+	Gpu   *GpuGoroutine
+	Index int
+	From  string
+}
+
+// nextThermalState decides the guard state from the current one and the
+// sensor reading. A gpu is paused (or throttled with -throttle-boost) at
+// maxTemp or MaxPower and resumed once it is Hysteresis degrees cooler and
+// below 90% of MaxPower. A throttled gpu that keeps heating up to maxTemp +
+// Hysteresis is paused.
+func nextThermalState(state string, r telemetry.Reading, maxTemp, hysteresis, maxPower int, throttle bool) string {
+	if r.Temp == 0 && r.Power == 0 {
+		return state // no sensors, keep what we have
+	}
+
+	hot := (maxTemp > 0 && r.Temp >= maxTemp) || (maxPower > 0 && r.Power >= maxPower)
+	cool := (maxTemp <= 0 || r.Temp <= maxTemp-hysteresis) &&
+		(maxPower <= 0 || r.Power*10 <= maxPower*9)
+
+	switch state {
+	case ThermalOk:
+		if hot {
+			if throttle {
+				return ThermalThrottled
+			}
+			return ThermalPaused
+		}
+	case ThermalThrottled:
+		if maxTemp > 0 && r.Temp >= maxTemp+hysteresis {
+			return ThermalPaused
+		}
+		if cool {
+			return ThermalOk
+		}
+	case ThermalPaused:
+		if cool {
+			return ThermalOk
+		}
+	}
+	return state
+}
+
+// RequireTelemetry excludes the gpus -max-temp / -max-power would not
+// protect because no telemetry reading can be matched to them, see
+// TelemetryKey. Already excluded gpus are left alone.
+func RequireTelemetry(gpus []GPUstruct) {
+	if config.Thermal.MaxTemp <= 0 && config.Thermal.MaxPower <= 0 {
+		return
+	}
+	for i := range gpus {
+		if gpus[i].Excluded == "" && TelemetryKey(gpus[i]) == "" {
+			gpus[i].Excluded = "no telemetry to apply -max-temp / -max-power to (unknown PCI bus id)"
+		}
+	}
+}
+
+// CheckThermal updates the guard state of the workers from their last
+// telemetry reading and returns the ones that changed. The caller holds
+// WorkersMu and stops / starts the miners.
+func CheckThermal(gpus []*GpuGoroutine) []ThermalChange {
+	config.Mu.RLock()
+	limits := config.Thermal
+	config.Mu.RUnlock()
+
+	var changes []ThermalChange
+	for i, g := range gpus {
+		maxTemp := limits.MaxTemp
+		if g.GpuData.MaxTemp > 0 {
+			maxTemp = g.GpuData.MaxTemp
+		}
+		next := nextThermalState(g.Thermal, g.Telemetry, maxTemp, limits.Hysteresis, limits.MaxPower, limits.Throttle > 0)
+		if next == g.Thermal {
+			continue
+		}
+		changes = append(changes, ThermalChange{Gpu: g, Index: i, From: g.Thermal})
+		g.Thermal = next
+	}
+	return changes
+}
+
+// Alert logs the change and runs -thermal-alert with the details in the
+// environment
+func (c ThermalChange) Alert() {
+	config.Mu.RLock()
+	limits := config.Thermal
+	config.Mu.RUnlock()
+
+	g := c.Gpu
+	desc := fmt.Sprintf("GPU %d %s (%d°C, %dW)", c.Index, g.GpuData.Model, g.Telemetry.Temp, g.Telemetry.Power)
+
+	switch g.Thermal {
+	case ThermalPaused:
+		mlog.LogError("Thermal guard: " + desc + " is too hot, miner paused")
+	case ThermalThrottled:
+		mlog.LogError("Thermal guard: " + desc + " is too hot, boost factor lowered to " + strconv.Itoa(limits.Throttle))
+	default:
+		mlog.LogOk("Thermal guard: " + desc + " cooled down, resuming from " + c.From)
+	}
+
+	if limits.AlertCmd == "" {
+		return
+	}
+	cmd := exec.Command(limits.AlertCmd)
+	state := g.Thermal
+	if state == ThermalOk {
+		state = "ok"
+	}
+	cmd.Env = append(os.Environ(),
+		"MININGPOOLCLI_GPU="+strconv.Itoa(c.Index),
+		"MININGPOOLCLI_GPU_MODEL="+g.GpuData.Model,
+		"MININGPOOLCLI_GPU_BUS_ID="+g.GpuData.BusId,
+		"MININGPOOLCLI_TEMP="+strconv.Itoa(g.Telemetry.Temp),
+		"MININGPOOLCLI_POWER="+strconv.Itoa(g.Telemetry.Power),
+		"MININGPOOLCLI_THERMAL="+state,
+	)
+	go func() {
+		if out, err := cmd.CombinedOutput(); err != nil {
+			mlog.LogError("-thermal-alert failed: " + err.Error() + " " + string(out))
+		}
+	}()
+}
@@ -0,0 +1,164 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package gpuwrk
+
+import (
+	"miningPoolCli/config"
+	"miningPoolCli/utils/helpers"
+	"miningPoolCli/utils/telemetry"
+	"testing"
+)
+
+type thermalStep struct {
+	temp, power int
+	want        string
+}
+
+func runThermal(t *testing.T, name string, g *GpuGoroutine, steps []thermalStep) {
+	t.Helper()
+	for i, step := range steps {
+		g.Telemetry = telemetry.Reading{Temp: step.temp, Power: step.power}
+		CheckThermal([]*GpuGoroutine{g})
+		if g.Thermal != step.want {
+			t.Errorf("%s step %d (%d°C, %dW): state %q, want %q", name, i, step.temp, step.power, g.Thermal, step.want)
+		}
+	}
+}
+
+func TestThermalGuard(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxTemp  int
+		maxPower int
+		throttle int
+		steps    []thermalStep
+	}{
+		{"pause", 80, 0, 0, []thermalStep{
+			{70, 0, ThermalOk},
+			{79, 0, ThermalOk},
+			{80, 0, ThermalPaused},
+			{75, 0, ThermalPaused}, // within the hysteresis
+			{0, 0, ThermalPaused},  // no reading, keep the state
+			{71, 0, ThermalPaused},
+			{70, 0, ThermalOk},
+			{80, 0, ThermalPaused},
+		}},
+		{"throttle", 80, 0, 1, []thermalStep{
+			{80, 0, ThermalThrottled},
+			{85, 0, ThermalThrottled},
+			{75, 0, ThermalThrottled},
+			{90, 0, ThermalPaused}, // max-temp + hysteresis
+			{85, 0, ThermalPaused},
+			{70, 0, ThermalOk},
+		}},
+		{"power", 0, 200, 0, []thermalStep{
+			{90, 150, ThermalOk},
+			{90, 200, ThermalPaused},
+			{90, 181, ThermalPaused}, // above 90%
+			{90, 180, ThermalOk},
+		}},
+		{"temp and power", 80, 200, 0, []thermalStep{
+			{60, 210, ThermalPaused},
+			{60, 150, ThermalOk},
+			{82, 150, ThermalPaused},
+			{65, 190, ThermalPaused}, // cool, but above 90% power
+			{65, 170, ThermalOk},
+		}},
+	}
+
+	for _, tt := range tests {
+		config.Configure()
+		config.Thermal.MaxTemp = tt.maxTemp
+		config.Thermal.MaxPower = tt.maxPower
+		config.Thermal.Throttle = tt.throttle
+
+		runThermal(t, tt.name, &GpuGoroutine{}, tt.steps)
+	}
+}
+
+func TestThermalGuardGpuMaxTemp(t *testing.T) {
+	config.Configure()
+	config.Thermal.MaxTemp = 80
+	config.Thermal.GpuMaxTemp = helpers.IndexedFlag{1: {"70"}}
+
+	gpus := []GPUstruct{
+		{GpuId: 0, Model: "RX 580", Backend: BackendOpenCL, BusId: "0000:01:00.0"},
+		{GpuId: 1, Model: "RX 580", Backend: BackendOpenCL, BusId: "0000:02:00.0"},
+	}
+	if err := ResolveGpuOverrides(gpus); err != nil {
+		t.Fatal(err)
+	}
+	ApplyMinerOverrides(gpus)
+	if !config.Thermal.Enabled() {
+		t.Fatal("thermal guard not enabled")
+	}
+
+	runThermal(t, "gpu 0", &GpuGoroutine{GpuData: gpus[0]}, []thermalStep{
+		{72, 0, ThermalOk},
+		{80, 0, ThermalPaused},
+		{70, 0, ThermalOk},
+	})
+	runThermal(t, "gpu 1", &GpuGoroutine{GpuData: gpus[1]}, []thermalStep{
+		{69, 0, ThermalOk},
+		{72, 0, ThermalPaused},
+		{61, 0, ThermalPaused},
+		{60, 0, ThermalOk},
+	})
+
+	// only the per-gpu limit set
+	config.Thermal.MaxTemp = 0
+	if !config.Thermal.Enabled() {
+		t.Fatal("thermal guard not enabled by -gpu-max-temp alone")
+	}
+	runThermal(t, "gpu 0 without -max-temp", &GpuGoroutine{GpuData: gpus[0]}, []thermalStep{
+		{95, 0, ThermalOk},
+	})
+}
+
+func TestThermalNeedsTelemetry(t *testing.T) {
+	withBus := GPUstruct{GpuId: 0, Model: "RX 580", Backend: BackendOpenCL, BusId: "0000:01:00.0"}
+	noBus := GPUstruct{GpuId: 1, Model: "RX 580", Backend: BackendOpenCL}
+
+	config.Configure()
+	config.Thermal.MaxTemp, config.Thermal.MaxPower, config.Thermal.GpuMaxTemp = 0, 0, nil
+	gpus := []GPUstruct{withBus, noBus}
+	RequireTelemetry(gpus)
+	if gpus[1].Excluded != "" {
+		t.Errorf("excluded without a thermal limit: %q", gpus[1].Excluded)
+	}
+
+	config.Thermal.MaxPower = 200
+	RequireTelemetry(gpus)
+	if gpus[0].Excluded != "" || gpus[1].Excluded == "" {
+		t.Errorf("with -max-power: excluded %q, %q, want only the gpu without a bus id", gpus[0].Excluded, gpus[1].Excluded)
+	}
+
+	config.Thermal.MaxPower = 0
+	config.Thermal.GpuMaxTemp = helpers.IndexedFlag{0: {"70"}}
+	if err := ResolveGpuOverrides([]GPUstruct{withBus, noBus}); err != nil {
+		t.Errorf("-gpu-max-temp for the gpu with a bus id: %v", err)
+	}
+	config.Thermal.GpuMaxTemp = helpers.IndexedFlag{1: {"70"}}
+	if err := ResolveGpuOverrides([]GPUstruct{withBus, noBus}); err == nil {
+		t.Error("accepted -gpu-max-temp for a gpu without telemetry")
+	}
+}
@@ -0,0 +1,110 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package helpers
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// SplitArgs splits a command line into arguments. Whitespace separates
+// arguments unless it is inside single or double quotes.
+func SplitArgs(s string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	var quote rune
+	inArg := false
+
+	for _, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, errors.New("unterminated quote in \"" + s + "\"")
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+
+	return args, nil
+}
+
+// IndexedFlag collects repeated "index:value" flags,
+// e.g. -gpu-env 0:CUDA_VISIBLE_DEVICES=0 -gpu-env 1:CUDA_VISIBLE_DEVICES=1
+type IndexedFlag map[int][]string
+
+func (f IndexedFlag) String() string {
+	var parts []string
+	for i, values := range f {
+		for _, v := range values {
+			parts = append(parts, strconv.Itoa(i)+":"+v)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (f IndexedFlag) Set(s string) error {
+	sep := strings.Index(s, ":")
+	if sep < 1 {
+		return errors.New("expected index:value, got \"" + s + "\"")
+	}
+
+	i, err := strconv.Atoi(s[:sep])
+	if err != nil || i < 0 {
+		return errors.New("invalid gpu index in \"" + s + "\"")
+	}
+
+	f[i] = append(f[i], s[sep+1:])
+	return nil
+}
+
+// ListFlag collects the values of a repeated flag
+type ListFlag []string
+
+func (f *ListFlag) String() string {
+	return strings.Join(*f, ", ")
+}
+
+func (f *ListFlag) Set(s string) error {
+	*f = append(*f, s)
+	return nil
+}
@@ -0,0 +1,88 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package helpers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []string
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"--algo ton  --pool x", []string{"--algo", "ton", "--pool", "x"}, false},
+		{"-a\t1\n-b 2\r\n", []string{"-a", "1", "-b", "2"}, false},
+		{`--name "my rig" --tag 'a b'`, []string{"--name", "my rig", "--tag", "a b"}, false},
+		{`--x "" y`, []string{"--x", "", "y"}, false},
+		{`--opt="a b"c`, []string{"--opt=a bc"}, false},
+		{`"it's"`, []string{"it's"}, false},
+		{`--name "my rig`, nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := SplitArgs(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("SplitArgs(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SplitArgs(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestIndexedFlag(t *testing.T) {
+	tests := []struct {
+		in      []string
+		want    IndexedFlag
+		wantErr bool
+	}{
+		{[]string{"0:A=1", "0:B=2", "1:A=3"}, IndexedFlag{0: {"A=1", "B=2"}, 1: {"A=3"}}, false},
+		{[]string{"2:--url=http://x:80"}, IndexedFlag{2: {"--url=http://x:80"}}, false},
+		{[]string{"3:"}, IndexedFlag{3: {""}}, false},
+		{[]string{"noindex"}, IndexedFlag{}, true},
+		{[]string{":A=1"}, IndexedFlag{}, true},
+		{[]string{"x:A=1"}, IndexedFlag{}, true},
+		{[]string{"-1:A=1"}, IndexedFlag{}, true},
+	}
+
+	for _, tt := range tests {
+		f := IndexedFlag{}
+		var err error
+		for _, s := range tt.in {
+			if err = f.Set(s); err != nil {
+				break
+			}
+		}
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Set(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(f, tt.want) {
+			t.Errorf("Set(%q) = %v, want %v", tt.in, f, tt.want)
+		}
+	}
+}
@@ -21,6 +21,8 @@ along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
 
 package helpers
 
+import "strings"
+
 func StringInSlice(a string, list []string) bool {
 	for _, b := range list {
 		if b == a {
@@ -29,3 +31,14 @@ func StringInSlice(a string, list []string) bool {
 	}
 	return false
 }
+
+// SplitList splits a comma separated list, empty items are dropped
+func SplitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
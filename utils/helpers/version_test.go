@@ -0,0 +1,51 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package helpers
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"3.1.0", "3.1.0", 0},
+		{"v3.1", "3.1.0", 0},
+		{"3.2.0", "3.1.9", 1},
+		{"3.10.0", "3.9.0", 1},
+		{"3.1.0-rc1", "3.1.0", -1},
+		{"3.1.0", "3.1.0-rc1", 1},
+		{"3.1.0-rc1", "3.1.0-rc2", -1},
+		{"3.1.0-rc.2", "3.1.0-rc.10", -1},
+		{"3.1.0-alpha", "3.1.0-beta", -1},
+		{"3.1.0-1", "3.1.0-alpha", -1},
+		{"3.1.0-rc", "3.1.0-rc.1", -1},
+		{"3.1.1-rc1", "3.1.0", 1},
+		{"3.1.0+build5", "3.1.0", 0},
+	}
+
+	for _, tt := range tests {
+		if got := CompareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package history keeps a bounded time series of hashrate, shares and miner
+// restarts per gpu, at 1 second resolution for an hour and 1 minute
+// resolution for a day
+package history
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"miningPoolCli/utils/files"
+	"sync"
+	"time"
+)
+
+type Sample struct {
+	Time     int64 `json:"time"`     // unix seconds, start of the interval
+	Hashrate int   `json:"hashrate"` // Mh/s, average over the interval
+	Accepted int   `json:"accepted"` // shares in the interval
+	Rejected int   `json:"rejected"`
+	Restarts int   `json:"restarts"` // miner restarts in the interval
+
+	sum, n int64 // of the hashrate samples
+}
+
+// Resolution of a series
+type Resolution struct {
+	Name string
+	Step int64 // seconds per sample
+	Size int   // samples kept
+}
+
+var (
+	Second = Resolution{"1s", 1, 3600}
+	Minute = Resolution{"1m", 60, 1440}
+)
+
+// Resolutions by name, for the "/history" endpoint
+var Resolutions = map[string]Resolution{
+	Second.Name: Second,
+	Minute.Name: Minute,
+}
+
+type gpuSeries struct {
+	Fine   []Sample `json:"fine"`   // Second
+	Coarse []Sample `json:"coarse"` // Minute
+}
+
+var store struct {
+	sync.Mutex
+	gpus map[string]*gpuSeries // by gpuwrk.Key
+}
+
+// bucket returns the sample of the interval t falls in, appended to series
+// if it's a new one
+func bucket(series *[]Sample, r Resolution, t int64) *Sample {
+	start := t - t%r.Step
+	s := *series
+	if len(s) > 0 && s[len(s)-1].Time == start {
+		return &s[len(s)-1]
+	}
+	if len(s) >= r.Size {
+		s = append(s[:0], s[len(s)-r.Size+1:]...)
+	}
+	*series = append(s, Sample{Time: start})
+	return &(*series)[len(*series)-1]
+}
+
+// update applies f to the current sample of each resolution of the gpu
+func update(key string, f func(s *Sample)) {
+	t := time.Now().Unix()
+
+	store.Lock()
+	defer store.Unlock()
+
+	if store.gpus == nil {
+		store.gpus = map[string]*gpuSeries{}
+	}
+	g := store.gpus[key]
+	if g == nil {
+		g = &gpuSeries{}
+		store.gpus[key] = g
+	}
+	f(bucket(&g.Fine, Second, t))
+	f(bucket(&g.Coarse, Minute, t))
+}
+
+// Hashrate records a hashrate reading of the gpu
+func Hashrate(key string, hashrate int) {
+	update(key, func(s *Sample) {
+		s.sum += int64(hashrate)
+		s.n++
+		s.Hashrate = int(s.sum / s.n)
+	})
+}
+
+// Share records a share answered by the pool
+func Share(key string, accepted bool) {
+	update(key, func(s *Sample) {
+		if accepted {
+			s.Accepted++
+		} else {
+			s.Rejected++
+		}
+	})
+}
+
+// Restart records a restart of the gpu's miner
+func Restart(key string) {
+	update(key, func(s *Sample) {
+		s.Restarts++
+	})
+}
+
+// Samples returns the samples of the gpu since the unix time, oldest first
+func Samples(key string, r Resolution, since int64) []Sample {
+	store.Lock()
+	defer store.Unlock()
+
+	result := []Sample{}
+	g := store.gpus[key]
+	if g == nil {
+		return result
+	}
+	series := g.Fine
+	if r == Minute {
+		series = g.Coarse
+	}
+	for _, s := range series {
+		if s.Time >= since {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// Average is the mean hashrate of the gpu over the last window, up to an
+// hour; 0 without samples
+func Average(key string, window time.Duration) int {
+	since := time.Now().Unix() - int64(window/time.Second)
+
+	var sum, n int64
+	for _, s := range Samples(key, Second, since) {
+		sum += s.sum
+		n += s.n
+	}
+	if n == 0 {
+		return 0
+	}
+	return int(sum / n)
+}
+
+// Save writes the history to path
+func Save(path string) error {
+	store.Lock()
+	data, err := json.Marshal(store.gpus)
+	store.Unlock()
+	if err != nil {
+		return err
+	}
+	return files.WriteAtomic(path, data, 0644)
+}
+
+// Load reads the history written by Save, it replaces the recorded one
+func Load(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	gpus := map[string]*gpuSeries{}
+	if err := json.Unmarshal(data, &gpus); err != nil {
+		return err
+	}
+
+	// the hashrate of the loaded samples counts as one reading
+	for _, g := range gpus {
+		for _, series := range [][]Sample{g.Fine, g.Coarse} {
+			for i := range series {
+				series[i].sum, series[i].n = int64(series[i].Hashrate), 1
+			}
+		}
+	}
+
+	store.Lock()
+	store.gpus = gpus
+	store.Unlock()
+	return nil
+}
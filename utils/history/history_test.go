@@ -0,0 +1,163 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package history
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func starts(series []Sample) []int64 {
+	var res []int64
+	for _, s := range series {
+		res = append(res, s.Time)
+	}
+	return res
+}
+
+func TestBucket(t *testing.T) {
+	tests := []struct {
+		name   string
+		r      Resolution
+		times  []int64
+		starts []int64
+		counts []int // bucket calls per sample
+	}{
+		{"second", Second, []int64{100, 100, 101, 103}, []int64{100, 101, 103}, []int{2, 1, 1}},
+		{"minute", Minute, []int64{100, 119, 120, 179, 180, 400}, []int64{60, 120, 180, 360}, []int{2, 2, 1, 1}},
+		{"rollover at size", Resolution{"10s", 10, 3}, []int64{0, 10, 15, 20, 30, 40, 55}, []int64{30, 40, 50}, []int{1, 1, 1}},
+		{"gap", Resolution{"10s", 10, 3}, []int64{0, 1000}, []int64{0, 1000}, []int{1, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var series []Sample
+			for _, ts := range tt.times {
+				bucket(&series, tt.r, ts).Accepted++
+				if len(series) > tt.r.Size {
+					t.Fatalf("%d samples kept, size is %d", len(series), tt.r.Size)
+				}
+			}
+			if got := starts(series); !reflect.DeepEqual(got, tt.starts) {
+				t.Errorf("sample times %v, want %v", got, tt.starts)
+			}
+			var counts []int
+			for _, s := range series {
+				counts = append(counts, s.Accepted)
+			}
+			if !reflect.DeepEqual(counts, tt.counts) {
+				t.Errorf("counts %v, want %v", counts, tt.counts)
+			}
+		})
+	}
+}
+
+func TestRecord(t *testing.T) {
+	store.Lock()
+	store.gpus = nil
+	store.Unlock()
+
+	Hashrate("gpu", 100)
+	Hashrate("gpu", 200)
+	Share("gpu", true)
+	Share("gpu", false)
+	Restart("gpu")
+
+	for _, r := range []Resolution{Second, Minute} {
+		samples := Samples("gpu", r, 0)
+		if len(samples) == 0 || len(samples) > 2 { // 2 if the second changed meanwhile
+			t.Fatalf("%s: %d samples", r.Name, len(samples))
+		}
+		var accepted, rejected, restarts int
+		for _, s := range samples {
+			accepted += s.Accepted
+			rejected += s.Rejected
+			restarts += s.Restarts
+		}
+		if accepted != 1 || rejected != 1 || restarts != 1 {
+			t.Errorf("%s: accepted %d, rejected %d, restarts %d, want 1 each", r.Name, accepted, rejected, restarts)
+		}
+	}
+	if got := Samples("other", Second, 0); len(got) != 0 {
+		t.Errorf("samples of an unknown gpu: %v", got)
+	}
+}
+
+func TestAverage(t *testing.T) {
+	now := time.Now().Unix()
+	store.Lock()
+	store.gpus = map[string]*gpuSeries{"gpu": {Fine: []Sample{
+		{Time: now - 120, sum: 1000, n: 1},
+		{Time: now - 30, sum: 200, n: 2},
+		{Time: now - 5, sum: 100, n: 1},
+	}}}
+	store.Unlock()
+
+	tests := []struct {
+		key    string
+		window time.Duration
+		want   int
+	}{
+		{"gpu", 10 * time.Second, 100},
+		{"gpu", time.Minute, 100}, // (200 + 100) / 3 readings
+		{"gpu", time.Hour, 325},
+		{"gpu", time.Second, 0},
+		{"other", time.Hour, 0},
+	}
+
+	for _, tt := range tests {
+		if got := Average(tt.key, tt.window); got != tt.want {
+			t.Errorf("Average(%q, %s) = %d, want %d", tt.key, tt.window, got, tt.want)
+		}
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	store.Lock()
+	store.gpus = map[string]*gpuSeries{"gpu": {
+		Fine:   []Sample{{Time: 60, Hashrate: 150, Accepted: 2, sum: 300, n: 2}},
+		Coarse: []Sample{{Time: 60, Hashrate: 150, Restarts: 1, sum: 300, n: 2}},
+	}}
+	store.Unlock()
+
+	path := filepath.Join(t.TempDir(), "h.json")
+	if err := Save(path); err != nil {
+		t.Fatal(err)
+	}
+	store.Lock()
+	store.gpus = nil
+	store.Unlock()
+	if err := Load(path); err != nil {
+		t.Fatal(err)
+	}
+
+	got := Samples("gpu", Second, 0)
+	want := []Sample{{Time: 60, Hashrate: 150, Accepted: 2, sum: 150, n: 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("loaded %+v, want %+v", got, want)
+	}
+	if got := Samples("gpu", Minute, 0); len(got) != 1 || got[0].Restarts != 1 {
+		t.Errorf("loaded coarse samples %+v", got)
+	}
+}
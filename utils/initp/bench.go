@@ -0,0 +1,124 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package initp
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"miningPoolCli/utils/getminer"
+	"miningPoolCli/utils/gpuwrk"
+	"miningPoolCli/utils/mlog"
+	"os"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// any valid address works, the miner only hashes it into the job
+const benchPoolAddress = "UQDu6s_r9_wmgWm5QgZuIeLep2fiSg4ijxGcJ0Sw8g4_9lvI"
+
+// BenchCommand is the "bench" subcommand: it runs the miners of the selected
+// gpus on a synthetic task, without contacting the pool, and reports the
+// hashrate of each gpu
+func BenchCommand(args []string) {
+	duration := flag.Duration("duration", time.Minute, "")
+	sequential := flag.Bool("sequential", false, "")
+	asJson := flag.Bool("json", false, "")
+	out := flag.String("out", "", "")
+	ParseFlags(args)
+
+	mlog.SetOutput(os.Stderr)
+
+	resolveMinerDir()
+	getminer.GetMiner()
+	gpus, _ := discoverOrExit()
+	gpus = gpuwrk.Selected(gpus)
+	if len(gpus) < 1 {
+		mlog.LogFatal("Gpus Not Found; run with -discover-only to see why")
+	}
+	if err := gpuwrk.ResolveGpuOverrides(gpus); err != nil {
+		mlog.LogFatal(err.Error())
+	}
+	gpuwrk.ApplyMinerOverrides(gpus)
+
+	seed := make([]byte, 16)
+	if _, err := rand.Read(seed); err != nil {
+		mlog.LogFatalStackError(err)
+	}
+	task := gpuwrk.BenchTask{
+		PoolAddress: benchPoolAddress,
+		Seed:        hex.EncodeToString(seed),
+		Complexity:  "01",
+	}
+
+	results := make([]gpuwrk.BenchResult, len(gpus))
+	bench := func(i int) {
+		mlog.LogInfo(fmt.Sprintf("Benchmarking GPU %d %s for %s", i, gpus[i].Model, duration))
+		results[i] = gpuwrk.Bench(gpus[i], task, *duration)
+		results[i].Index = i
+	}
+	if *sequential {
+		for i := range gpus {
+			bench(i)
+		}
+	} else {
+		var wg sync.WaitGroup
+		for i := range gpus {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				bench(i)
+			}(i)
+		}
+		wg.Wait()
+	}
+
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		mlog.LogFatalStackError(err)
+	}
+	if *out != "" {
+		if err := ioutil.WriteFile(*out, data, 0644); err != nil {
+			mlog.LogFatal("can't write -out: " + err.Error())
+		}
+	}
+	if *asJson {
+		fmt.Println(string(data))
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "INDEX\tBACKEND\tMODEL\tSAMPLES\tMIN\tAVG\tMAX\tSTABILITY\tERROR")
+	var total float64
+	for _, r := range results {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%.2f\t%.2f\t%.2f\t%.1f%%\t%s\n",
+			r.Index, r.Backend, r.Model, r.Samples, r.Min, r.Avg, r.Max, r.Stability, r.Error)
+		total += r.Avg
+	}
+	fmt.Fprintf(w, "\t\tTotal\t\t\t%.2f\t\t\t\n", total)
+	w.Flush()
+	fmt.Println("Hashrates in Mh/s")
+}
@@ -0,0 +1,159 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package initp
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"miningPoolCli/utils/ctl"
+	"miningPoolCli/utils/gpuwrk"
+	"miningPoolCli/utils/mlog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+type ctlStatus struct {
+	State         string `json:"state"`
+	MinerUptime   int64  `json:"miner_uptime"`
+	TotalHashrate int    `json:"total_hashrate"`
+	Gpus          []struct {
+		Model    string `json:"device_name"`
+		Backend  string `json:"backend"`
+		BusId    string `json:"bus_id"`
+		Hashrate int    `json:"hashrate"`
+		State    string `json:"state"`
+		Temp     int    `json:"temp"`
+		Fan      int    `json:"fan"`
+		Power    int    `json:"power"`
+	} `json:"gpus"`
+}
+
+// CtlCommand runs the status, pause, resume, restart-gpu or stop subcommand
+// against the instance found in the
+// address file of -data-dir (or -addr). pause and resume take an optional
+// gpu index, restart-gpu a required one.
+func CtlCommand(cmd string, args []string) {
+	gpu := -1
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		index, err := strconv.Atoi(args[0])
+		if err != nil || index < 0 || cmd == "status" || cmd == "stop" {
+			mlog.SetOutput(os.Stderr)
+			mlog.LogFatal("unexpected argument \"" + args[0] + "\" of " + cmd)
+		}
+		gpu, args = index, args[1:]
+	}
+
+	addr := flag.String("addr", "", "")
+	asJson := flag.Bool("json", false, "")
+	ParseFlags(args)
+
+	mlog.SetOutput(os.Stderr)
+
+	if cmd == "restart-gpu" && gpu < 0 {
+		mlog.LogFatal("usage: restart-gpu <index> [flags]")
+	}
+
+	a, err := ctl.Addr(*addr)
+	if err != nil {
+		mlog.LogFatal(err.Error())
+	}
+	client, err := ctl.New(a)
+	if err != nil {
+		mlog.LogFatal(err.Error())
+	}
+
+	method, path := ctlRequest(cmd, gpu)
+	body, err := client.Call(method, path)
+	if err != nil {
+		if cmd == "stop" && strings.Contains(err.Error(), "404") {
+			mlog.LogFatal("the instance doesn't handle /kill, start it with -handle-kill")
+		}
+		mlog.LogFatal(err.Error())
+	}
+
+	if *asJson {
+		fmt.Println(strings.TrimSpace(string(body)))
+		return
+	}
+	if cmd != "status" {
+		target := "all gpus"
+		if gpu >= 0 {
+			target = "gpu " + strconv.Itoa(gpu)
+		}
+		switch cmd {
+		case "stop":
+			fmt.Println("stopped " + client.Addr)
+		default:
+			fmt.Println(cmd + ": ok, " + target)
+		}
+		return
+	}
+
+	if err := printStatus(os.Stdout, client.Addr, body); err != nil {
+		mlog.LogFatal(err.Error())
+	}
+}
+
+// ctlRequest is the api call of a subcommand, gpu -1 for all of them
+func ctlRequest(cmd string, gpu int) (method, path string) {
+	switch cmd {
+	case "pause", "resume", "restart-gpu":
+		action := strings.TrimSuffix(cmd, "-gpu")
+		if gpu >= 0 {
+			return http.MethodPost, "/gpu/" + strconv.Itoa(gpu) + "/" + action
+		}
+		return http.MethodPost, "/" + action
+	case "stop":
+		return http.MethodPost, "/kill"
+	}
+	return http.MethodGet, "/stat"
+}
+
+// printStatus writes the /stat response of the instance at addr as a table
+func printStatus(out io.Writer, addr string, body []byte) error {
+	var status ctlStatus
+	if err := json.Unmarshal(body, &status); err != nil {
+		return errors.New("can't parse /stat: " + err.Error())
+	}
+
+	fmt.Fprintf(out, "%s, state %s, up %s, total ~%d Mh\n\n", addr, status.State,
+		(time.Duration(status.MinerUptime) * time.Second).String(), status.TotalHashrate)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "INDEX\tSTATE\tBACKEND\tMODEL\tPCI BUS\tHASHRATE\tTEMP\tFAN\tPOWER")
+	for i, g := range status.Gpus {
+		bus := g.BusId
+		if bus == "" {
+			bus = "-"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%d Mh\t%s\t%s\t%s\n", i, g.State, g.Backend,
+			g.Model, bus, g.Hashrate, gpuwrk.FormatSensor(g.Temp, "°C"), gpuwrk.FormatSensor(g.Fan, "%"), gpuwrk.FormatSensor(g.Power, " W"))
+	}
+	return w.Flush()
+}
@@ -0,0 +1,77 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package initp
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCtlRequest(t *testing.T) {
+	tests := []struct {
+		cmd          string
+		gpu          int
+		method, path string
+	}{
+		{"status", -1, http.MethodGet, "/stat"},
+		{"pause", -1, http.MethodPost, "/pause"},
+		{"pause", 2, http.MethodPost, "/gpu/2/pause"},
+		{"resume", -1, http.MethodPost, "/resume"},
+		{"resume", 0, http.MethodPost, "/gpu/0/resume"},
+		{"restart-gpu", 1, http.MethodPost, "/gpu/1/restart"},
+		{"stop", -1, http.MethodPost, "/kill"},
+	}
+
+	for _, tt := range tests {
+		if method, path := ctlRequest(tt.cmd, tt.gpu); method != tt.method || path != tt.path {
+			t.Errorf("ctlRequest(%s, %d) = %s %s, want %s %s", tt.cmd, tt.gpu, method, path, tt.method, tt.path)
+		}
+	}
+}
+
+func TestPrintStatus(t *testing.T) {
+	body := `{"state": "mining", "miner_uptime": 3725, "total_hashrate": 5, "gpus": [
+		{"device_name": "RTX 3060", "backend": "cuda", "bus_id": "0000:01:00.0", "hashrate": 2, "state": "mining", "temp": 61, "fan": 70, "power": 120},
+		{"device_name": "RX 580", "backend": "opencl", "hashrate": 3, "state": "paused"}
+	]}`
+
+	var out bytes.Buffer
+	if err := printStatus(&out, "127.0.0.1:8080", []byte(body)); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"127.0.0.1:8080, state mining, up 1h2m5s, total ~5 Mh",
+		"",
+		"INDEX  STATE   BACKEND  MODEL     PCI BUS       HASHRATE  TEMP  FAN  POWER",
+		"0      mining  cuda     RTX 3060  0000:01:00.0  2 Mh      61°C  70%  120 W",
+		"1      paused  opencl   RX 580    -             3 Mh      -     -    -",
+	}
+	if got := strings.TrimRight(out.String(), "\n"); got != strings.Join(want, "\n") {
+		t.Errorf("printStatus =\n%s\nwant\n%s", got, strings.Join(want, "\n"))
+	}
+
+	if err := printStatus(&out, "127.0.0.1:8080", []byte("404 page not found")); err == nil {
+		t.Error("invalid /stat accepted")
+	}
+}
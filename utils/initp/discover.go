@@ -0,0 +1,210 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package initp
+
+import (
+	"errors"
+	"fmt"
+	"miningPoolCli/config"
+	"miningPoolCli/utils/gpuwrk"
+	"miningPoolCli/utils/mlog"
+	"miningPoolCli/utils/pci"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// DiscoverGpus runs the miners to list the devices, or takes the "devices"
+// of the -config file. All devices are returned, the ones that must not be
+// used have Excluded set. The lines explain how the miner output was parsed.
+// It fails if a miner can't be run.
+func DiscoverGpus() ([]gpuwrk.GPUstruct, []gpuwrk.DiscoveryLine, error) {
+	cudaPath, openCLPath := minerPaths()
+
+	// CUDA enumerates the fastest device first by default, PCI order lets
+	// us tell which card is which
+	if os.Getenv("CUDA_DEVICE_ORDER") == "" {
+		os.Setenv("CUDA_DEVICE_ORDER", "PCI_BUS_ID")
+	}
+
+	if len(config.File.Devices) > 0 {
+		gpus, lines := manualGpus(cudaPath, openCLPath)
+		gpuwrk.RequireTelemetry(gpus)
+		return gpus, lines, nil
+	}
+
+	var allGpus []gpuwrk.GPUstruct
+	var allLines []gpuwrk.DiscoveryLine
+
+	if cudaPath != "" {
+		gpus, lines, err := gpuwrk.SearchGpusCuda(cudaPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, gpu := range gpus {
+			gpu.StartPath = cudaPath
+			allGpus = append(allGpus, gpu)
+		}
+		allLines = append(allLines, lines...)
+	}
+
+	if openCLPath != "" {
+		gpus, lines, err := gpuwrk.SearchGpusOpenCL(openCLPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, gpu := range gpus {
+			gpu.StartPath = openCLPath
+			allGpus = append(allGpus, gpu)
+		}
+		allLines = append(allLines, lines...)
+	}
+
+	if config.OS.OperatingSystem == config.OSType.Linux {
+		if devices, err := pci.DisplayDevices(config.Paths.SysfsRoot); err == nil {
+			gpuwrk.AssignBusIds(allGpus, devices, os.Getenv("CUDA_DEVICE_ORDER") == "PCI_BUS_ID")
+		} else {
+			mlog.LogInfo("warn: can't read PCI devices: " + err.Error())
+		}
+	}
+
+	gpuwrk.DedupBackends(allGpus)
+	gpuwrk.SelectGpus(allGpus)
+	gpuwrk.RequireTelemetry(allGpus)
+	return allGpus, allLines, nil
+}
+
+// manualGpus returns the devices listed in the -config file, no miner
+// output is parsed and no duplicates are removed
+func manualGpus(cudaPath, openCLPath string) ([]gpuwrk.GPUstruct, []gpuwrk.DiscoveryLine) {
+	var gpus []gpuwrk.GPUstruct
+	var lines []gpuwrk.DiscoveryLine
+
+	for n, dev := range config.File.Devices {
+		line := gpuwrk.DiscoveryLine{
+			Backend: dev.Backend,
+			Line:    "devices[" + strconv.Itoa(n) + "] in " + config.ConfigFile,
+		}
+
+		gpu := gpuwrk.GPUstruct{
+			GpuId:      dev.DeviceId,
+			PlatformId: dev.PlatformId,
+			Model:      dev.Label,
+			Backend:    dev.Backend,
+			BusId:      pci.NormalizeBusId(dev.BusId),
+		}
+		switch dev.Backend {
+		case gpuwrk.BackendCuda:
+			gpu.StartPath = cudaPath
+		case gpuwrk.BackendOpenCL:
+			gpu.StartPath = openCLPath
+		default:
+			line.Reason = "unknown backend \"" + dev.Backend + "\", expected cuda or opencl"
+			lines = append(lines, line)
+			continue
+		}
+		if gpu.StartPath == "" {
+			line.Reason = "no " + dev.Backend + " miner on this OS"
+			lines = append(lines, line)
+			continue
+		}
+		if gpu.Model == "" {
+			gpu.Model = dev.Backend + " device #" + strconv.Itoa(dev.DeviceId)
+		}
+
+		line.Accepted = true
+		line.Reason = "manual device \"" + gpu.Model + "\""
+		lines = append(lines, line)
+		gpus = append(gpus, gpu)
+	}
+
+	gpuwrk.SelectGpus(gpus)
+	return gpus, lines
+}
+
+// PrintDiscovery writes the -discover-only report
+func PrintDiscovery(gpus []gpuwrk.GPUstruct, lines []gpuwrk.DiscoveryLine) {
+	fmt.Println("Miner output:")
+	for _, line := range lines {
+		verdict := "REJECT"
+		if line.Accepted {
+			verdict = "ACCEPT"
+		}
+		fmt.Printf("  %s [%s] %s\n         %s\n", verdict, line.Backend, line.Line, line.Reason)
+	}
+
+	fmt.Println("\nDevices:")
+	for _, gpu := range gpus {
+		state := "mine"
+		if gpu.Excluded != "" {
+			state = "skip: " + gpu.Excluded
+		}
+		fmt.Printf("  %-7s platform %d device %d  %-30s %-13s %s\n",
+			gpu.Backend, gpu.PlatformId, gpu.GpuId, gpu.Model, gpu.BusId, state)
+	}
+}
+
+// minerPaths returns the cuda and opencl executables to run, an empty
+// string means the backend is not available on this OS
+func minerPaths() (cudaPath, openCLPath string) {
+	if config.MinerOverrides.CudaPath != "" {
+		cudaPath = config.MinerOverrides.CudaPath
+		mlog.LogInfo("Using custom CUDA miner: " + cudaPath)
+	} else if config.MinerGetter.CurrExecNameCuda != "" {
+		cudaPath = filepath.Join(config.Paths.MinerDir, config.MinerGetter.CurrExecNameCuda)
+	}
+
+	if config.MinerOverrides.OpenCLPath != "" {
+		openCLPath = config.MinerOverrides.OpenCLPath
+		mlog.LogInfo("Using custom OpenCL miner: " + openCLPath)
+	} else if config.MinerGetter.CurrExecNameOpenCL != "" {
+		openCLPath = filepath.Join(config.Paths.MinerDir, config.MinerGetter.CurrExecNameOpenCL)
+	}
+
+	return cudaPath, openCLPath
+}
+
+// RediscoverGpus discovers the gpus again while mining, returning the ones
+// to mine on with the miner overrides resolved at startup applied. Finding
+// no gpu at all is an error too, more likely a driver hiccup than every
+// card gone, so the running workers are kept.
+func RediscoverGpus() ([]gpuwrk.GPUstruct, error) {
+	gpus, _, err := DiscoverGpus()
+	if err != nil {
+		return nil, err
+	}
+	selected := gpuwrk.Selected(gpus)
+	if len(selected) == 0 {
+		return nil, errors.New("no GPU found")
+	}
+	gpuwrk.ApplyMinerOverrides(selected)
+	return selected, nil
+}
+
+// discoverOrExit is DiscoverGpus for the commands that can't go on without
+func discoverOrExit() ([]gpuwrk.GPUstruct, []gpuwrk.DiscoveryLine) {
+	gpus, lines, err := DiscoverGpus()
+	if err != nil {
+		mlog.LogFatal("GPU discovery failed: " + err.Error())
+	}
+	return gpus, lines
+}
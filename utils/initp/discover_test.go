@@ -0,0 +1,88 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package initp
+
+import (
+	"io/ioutil"
+	"miningPoolCli/config"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestManualGpus(t *testing.T) {
+	config.Configure()
+	config.Thermal.MaxTemp, config.Thermal.MaxPower = 0, 0
+
+	path := filepath.Join(t.TempDir(), "rig.json")
+	file := `{
+		"gpus": ["#0", "#1", "#2"],
+		"devices": [
+			{"backend": "cuda", "device_id": 0, "label": "RTX 3060", "bus_id": "01:00.0"},
+			{"backend": "opencl", "platform_id": 1, "device_id": 2},
+			{"backend": "vulkan", "device_id": 0},
+			{"backend": "cuda", "device_id": 3, "label": "RTX 3070"},
+			{"backend": "opencl", "device_id": 1, "label": "Intel(R) UHD Graphics 630"}
+		]
+	}`
+	if err := ioutil.WriteFile(path, []byte(file), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := config.DefaultSettings()
+	if err := s.LoadFile(path, map[string]bool{}); err != nil {
+		t.Fatal(err)
+	}
+	config.File, config.GpuSelect = s.File, s.GpuSelect
+	defer config.Configure()
+
+	gpus, lines := manualGpus("/miners/pow-miner-cuda", "/miners/pow-miner-opencl")
+
+	want := []struct {
+		model, busId, startPath, excluded string
+	}{
+		{"RTX 3060", "0000:01:00.0", "/miners/pow-miner-cuda", ""},
+		{"opencl device #2", "", "/miners/pow-miner-opencl", ""},
+		{"RTX 3070", "", "/miners/pow-miner-cuda", "not in -gpus"},
+		{"Intel(R) UHD Graphics 630", "", "/miners/pow-miner-opencl", "integrated GPU"},
+	}
+	if len(gpus) != len(want) {
+		t.Fatalf("gpus = %+v", gpus)
+	}
+	for i, w := range want {
+		gpu := gpus[i]
+		if gpu.Model != w.model || gpu.BusId != w.busId || gpu.StartPath != w.startPath ||
+			!strings.HasPrefix(gpu.Excluded, w.excluded) || (w.excluded == "") != (gpu.Excluded == "") {
+			t.Errorf("gpus[%d] = %+v, want %+v", i, gpu, w)
+		}
+	}
+
+	if len(lines) != 5 || lines[2].Accepted || !strings.Contains(lines[2].Reason, "unknown backend") {
+		t.Errorf("lines = %+v", lines)
+	}
+
+	// no OpenCL miner on this OS
+	config.GpuSelect.Include = nil
+	gpus, lines = manualGpus("/miners/pow-miner-cuda", "")
+	if len(gpus) != 2 || lines[1].Accepted || !strings.Contains(lines[1].Reason, "no opencl miner") {
+		t.Errorf("without an OpenCL miner: gpus = %+v, lines = %+v", gpus, lines)
+	}
+}
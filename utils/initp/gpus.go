@@ -0,0 +1,92 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package initp
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"miningPoolCli/utils/getminer"
+	"miningPoolCli/utils/gpuwrk"
+	"miningPoolCli/utils/mlog"
+	"os"
+	"strconv"
+	"text/tabwriter"
+)
+
+type gpuRow struct {
+	Index *int `json:"index"` // position in the mining list, null if excluded
+	gpuwrk.GPUstruct
+}
+
+// GpusCommand is the "gpus" subcommand: it runs discovery with the usual
+// selection flags and prints the devices without contacting the pool
+func GpusCommand(args []string) {
+	asJson := flag.Bool("json", false, "")
+	ParseFlags(args)
+
+	// keep stdout for the table / json
+	mlog.SetOutput(os.Stderr)
+
+	resolveMinerDir()
+	getminer.GetMiner()
+	gpus, _ := discoverOrExit()
+
+	var rows []gpuRow
+	index := 0
+	for _, gpu := range gpus {
+		row := gpuRow{GPUstruct: gpu}
+		if gpu.Excluded == "" {
+			i := index
+			row.Index = &i
+			index++
+		}
+		rows = append(rows, row)
+	}
+
+	if *asJson {
+		if rows == nil {
+			rows = []gpuRow{}
+		}
+		data, err := json.MarshalIndent(rows, "", "  ")
+		if err != nil {
+			mlog.LogFatalStackError(err)
+		}
+		fmt.Println(string(data))
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "INDEX\tBACKEND\tPLATFORM\tDEVICE\tMODEL\tPCI BUS\tEXCLUDED")
+	for _, row := range rows {
+		index, bus := "-", row.BusId
+		if row.Index != nil {
+			index = strconv.Itoa(*row.Index)
+		}
+		if bus == "" {
+			bus = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\t%s\t%s\n",
+			index, row.Backend, row.PlatformId, row.GpuId, row.Model, bus, row.Excluded)
+	}
+	w.Flush()
+}
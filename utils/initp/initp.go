@@ -22,38 +22,246 @@ along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
 package initp
 
 import (
+	"errors"
 	"flag"
 	"fmt"
-	"log"
 	"miningPoolCli/config"
 	"miningPoolCli/utils/api"
+	"miningPoolCli/utils/export"
 	"miningPoolCli/utils/getminer"
 	"miningPoolCli/utils/gpuwrk"
+	"miningPoolCli/utils/helpers"
 	"miningPoolCli/utils/mlog"
 	"os"
-	"path/filepath"
-	"regexp"
 	"runtime"
+	"strings"
 	"time"
 )
 
-func InitProgram() []gpuwrk.GPUstruct {
+// ParseFlags configures the program from the command line arguments (without
+// the program name and subcommand). It is shared by the miner and the
+// subcommands.
+func ParseFlags(args []string) {
 	config.Configure()
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, config.Texts.GlobalHelpText)
 	}
 
-	flag.StringVar(&config.ServerSettings.AuthKey, "pool-id", "", "")
-	flag.StringVar(&config.ServerSettings.MiningPoolServerURL, "url", "https://ninja.tonlens.com", "")
-	flag.BoolVar(&config.UpdateStatsFile, "stats", false, "") // for Hive OS
-
-	flag.BoolVar(&config.NetSrv.RunThis, "serve-stat", false, "")     // run http server with miner stat
-	flag.BoolVar(&config.NetSrv.HandleKill, "handle-kill", false, "") // handle /kill (os.Exit by http)
+	s, err := parseSettings(flag.CommandLine, args)
+	if err != nil {
+		mlog.LogFatal(err.Error())
+	}
+	s.Apply()
 
-	flag.Parse()
 	config.OS.OperatingSystem, config.OS.Architecture = runtime.GOOS, runtime.GOARCH
 
+	switch config.OS.OperatingSystem {
+	case config.OSType.Linux:
+		config.MinerGetter.CurrExecNameOpenCL = config.MinerGetter.UbuntuSettings.ExecutableName
+		config.MinerGetter.CurrExecNameCuda = config.MinerGetter.UbuntuSettings.ExecutableNameCuda
+	case config.OSType.Win:
+		config.MinerGetter.CurrExecNameOpenCL = config.MinerGetter.WinSettings.ExecutableName
+		config.MinerGetter.CurrExecNameCuda = config.MinerGetter.WinSettings.ExecutableNameCuda
+	case config.OSType.Macos:
+		config.MinerGetter.CurrExecNameOpenCL = config.MinerGetter.MacSettings.ExecutableName
+		config.MinerGetter.CurrExecNameCuda = config.MinerGetter.MacSettings.ExecutableNameCuda
+	}
+
+	resolveDataDir()
+}
+
+// parseSettings parses the arguments and the -hive-config and -config files
+// into staged settings, nothing is applied. All problems found are
+// returned as one error.
+func parseSettings(fs *flag.FlagSet, args []string) (config.Settings, error) {
+	s := config.DefaultSettings()
+
+	fs.StringVar(&s.ServerSettings.AuthKey, "pool-id", "", "")
+	fs.StringVar(&s.ServerSettings.MiningPoolServerURL, "url", "https://ninja.tonlens.com", "")
+	fs.StringVar(&s.ServerSettings.Worker, "worker", "", "")
+	fs.BoolVar(&s.UpdateStatsFile, "stats", false, "") // for Hive OS
+	var statsFormats string
+	fs.StringVar(&statsFormats, "stats-format", "", "")
+
+	fs.BoolVar(&s.NetSrv.RunThis, "serve-stat", false, "")     // run http server with miner stat
+	fs.BoolVar(&s.NetSrv.HandleKill, "handle-kill", false, "") // handle /kill (os.Exit by http)
+	fs.Var(&s.NetSrv.Listen, "stat-listen", "")
+	fs.StringVar(&s.NetSrv.Token, "stat-token", "", "")
+	fs.StringVar(&s.NetSrv.BasicAuth, "stat-basic-auth", "", "")
+	fs.StringVar(&s.NetSrv.TLSCert, "stat-tls-cert", "", "")
+	fs.StringVar(&s.NetSrv.TLSKey, "stat-tls-key", "", "")
+	var statAllow string
+	fs.StringVar(&statAllow, "stat-allow", "", "")
+	fs.StringVar(&s.NetSrv.ClaymoreAddr, "claymore-api", "", "")
+	fs.StringVar(&s.NetSrv.ClaymorePassword, "claymore-password", "", "")
+
+	fs.StringVar(&s.MinerOverrides.OpenCLPath, "miner-opencl", "", "")
+	fs.StringVar(&s.MinerOverrides.CudaPath, "miner-cuda", "", "")
+	fs.StringVar(&s.MinerOverrides.Args, "miner-args", "", "")
+	fs.StringVar(&s.MinerOverrides.ArgsOpenCL, "miner-args-opencl", "", "")
+	fs.StringVar(&s.MinerOverrides.ArgsCuda, "miner-args-cuda", "", "")
+	fs.Var(s.MinerOverrides.GpuArgs, "gpu-args", "")
+	fs.Var(s.MinerOverrides.GpuEnv, "gpu-env", "")
+
+	fs.StringVar(&s.Paths.DataDir, "data-dir", "", "")
+	fs.StringVar(&s.Paths.MinerDir, "miner-dir", "", "")
+	fs.StringVar(&s.Paths.LogFile, "log-file", "", "")
+
+	fs.StringVar(&s.MinerGetter.ManifestURL, "miner-manifest", s.MinerGetter.ManifestURL, "")
+	fs.StringVar(&s.MinerGetter.ManifestKey, "miner-manifest-key", "", "")
+	fs.BoolVar(&s.MinerGetter.Update, "miner-update", false, "")
+	fs.BoolVar(&s.MinerGetter.Rollback, "miner-rollback", false, "")
+	fs.BoolVar(&s.MinerGetter.SkipVerify, "skip-miner-verify", false, "")
+
+	fs.StringVar(&s.UpdateSettings.FeedURL, "update-feed", s.UpdateSettings.FeedURL, "")
+	fs.StringVar(&s.UpdateSettings.PublicKey, "update-key", "", "")
+	fs.BoolVar(&s.UpdateSettings.Auto, "auto-update", false, "")
+	fs.DurationVar(&s.UpdateSettings.Interval, "auto-update-interval", s.UpdateSettings.Interval, "")
+
+	var includeGpus, excludeGpus string
+	fs.StringVar(&includeGpus, "gpus", "", "")
+	fs.StringVar(&excludeGpus, "exclude-gpus", "", "")
+	fs.BoolVar(&s.GpuSelect.IncludeIntegrated, "include-integrated", false, "")
+	fs.StringVar(&s.ConfigFile, "config", "", "")
+
+	var gpuBackends string
+	fs.StringVar(&s.GpuSelect.Backend, "backend", s.GpuSelect.Backend, "")
+	fs.StringVar(&gpuBackends, "gpu-backend", "", "")
+	fs.StringVar(&s.Paths.SysfsRoot, "sysfs-root", s.Paths.SysfsRoot, "")
+	fs.BoolVar(&s.DiscoverOnly, "discover-only", false, "")
+	fs.BoolVar(&s.TUI, "tui", false, "")
+	fs.StringVar(&s.HistoryFile, "history-file", "", "")
+	fs.DurationVar(&s.RediscoverInterval, "rediscover-interval", 0, "")
+
+	fs.StringVar(&s.Telemetry.NvidiaSmi, "nvidia-smi", s.Telemetry.NvidiaSmi, "")
+	fs.StringVar(&s.Telemetry.RocmSmi, "rocm-smi", s.Telemetry.RocmSmi, "")
+	fs.DurationVar(&s.Telemetry.Interval, "telemetry-interval", s.Telemetry.Interval, "")
+
+	fs.IntVar(&s.Thermal.MaxTemp, "max-temp", 0, "")
+	fs.IntVar(&s.Thermal.Hysteresis, "temp-hysteresis", s.Thermal.Hysteresis, "")
+	fs.IntVar(&s.Thermal.MaxPower, "max-power", 0, "")
+	fs.IntVar(&s.Thermal.Throttle, "throttle-boost", 0, "")
+	fs.StringVar(&s.Thermal.AlertCmd, "thermal-alert", "", "")
+	fs.Var(s.Thermal.GpuMaxTemp, "gpu-max-temp", "")
+
+	var hiveConfig string
+	fs.StringVar(&hiveConfig, "hive-config", "", "")
+
+	if path := hiveConfigArg(args); path != "" {
+		hiveArgs, err := config.HiveArgs(path)
+		if err != nil {
+			return s, errors.New("can't load -hive-config " + path + ": " + err.Error())
+		}
+		args = append(hiveArgs, args...)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return s, err
+	}
+
+	var errs []string
+	fail := func(msg string) {
+		errs = append(errs, msg)
+	}
+
+	if fs.NArg() > 0 {
+		fail("unexpected argument \"" + fs.Arg(0) + "\"; for help run with -h flag")
+	}
+
+	if len(s.NetSrv.Listen) > 0 {
+		s.NetSrv.RunThis = true
+	}
+	s.NetSrv.AllowedHosts = helpers.SplitList(statAllow)
+	if (s.NetSrv.TLSCert == "") != (s.NetSrv.TLSKey == "") {
+		fail("-stat-tls-cert and -stat-tls-key must be given together")
+	}
+	if s.NetSrv.BasicAuth != "" && !strings.Contains(s.NetSrv.BasicAuth, ":") {
+		fail("-stat-basic-auth must be user:password")
+	}
+
+	s.StatsFormats = helpers.SplitList(statsFormats)
+	if err := export.CheckFormats(s.StatsFormats); err != nil {
+		fail(err.Error())
+	}
+
+	s.GpuSelect.Include = helpers.SplitList(includeGpus)
+	s.GpuSelect.Exclude = helpers.SplitList(excludeGpus)
+	for _, item := range helpers.SplitList(gpuBackends) {
+		sep := strings.LastIndex(item, "=")
+		if sep < 1 {
+			fail("invalid -gpu-backend item \"" + item + "\", expected busid=backend")
+			continue
+		}
+		s.GpuSelect.BackendByBus[item[:sep]] = item[sep+1:]
+	}
+
+	if s.ConfigFile != "" {
+		flagsSet := map[string]bool{}
+		fs.Visit(func(f *flag.Flag) { flagsSet[f.Name] = true })
+		if err := s.LoadFile(s.ConfigFile, flagsSet); err != nil {
+			fail("can't load -config " + s.ConfigFile + ": " + err.Error())
+		}
+	}
+
+	if s.Thermal.Enabled() && s.Telemetry.Interval <= 0 {
+		fail("-max-temp / -max-power need telemetry, -telemetry-interval must be > 0")
+	}
+	for _, tokens := range [][]string{s.GpuSelect.Include, s.GpuSelect.Exclude} {
+		if err := gpuwrk.CheckGpuTokens(tokens); err != nil {
+			fail(err.Error())
+		}
+	}
+	if err := gpuwrk.CheckBackendPreferences(s.GpuSelect.Backend, s.GpuSelect.BackendByBus); err != nil {
+		fail(err.Error())
+	}
+	if err := s.MinerOverrides.Check(); err != nil {
+		fail(err.Error())
+	}
+	if err := s.Thermal.Check(); err != nil {
+		fail(err.Error())
+	}
+
+	if len(errs) > 0 {
+		return s, errors.New(strings.Join(errs, "; "))
+	}
+	return s, nil
+}
+
+// hiveConfigArg finds the -hive-config path before the flags are parsed, its
+// content goes in front of the other arguments
+func hiveConfigArg(args []string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		name := strings.TrimLeft(arg, "-")
+		if len(name) == len(arg) || len(arg)-len(name) > 2 {
+			continue
+		}
+		if name == "hive-config" && i+1 < len(args) {
+			return args[i+1]
+		}
+		if strings.HasPrefix(name, "hive-config=") {
+			return strings.TrimPrefix(name, "hive-config=")
+		}
+	}
+	return ""
+}
+
+func InitProgram() []gpuwrk.GPUstruct {
+	ParseFlags(os.Args[1:])
+	if len(config.StatsFormats) > 0 && !config.UpdateStatsFile {
+		mlog.LogInfo("warn: -stats-format has no effect without -stats")
+	}
+
+	if config.DiscoverOnly {
+		resolveMinerDir()
+		getminer.GetMiner()
+		PrintDiscovery(discoverOrExit())
+		os.Exit(0)
+	}
+
 	switch "" {
 	case config.ServerSettings.AuthKey:
 		mlog.LogFatal("Flag -pool-id is required; for help run with -h flag")
@@ -80,21 +288,10 @@ func InitProgram() []gpuwrk.GPUstruct {
 	}
 
 	mlog.LogInfo("Using mining pool API url: " + config.ServerSettings.MiningPoolServerURL)
-
-	switch config.OS.OperatingSystem {
-	case config.OSType.Linux:
-		config.MinerGetter.CurrExecNameOpenCL = config.MinerGetter.UbuntuSettings.ExecutableName
-		config.MinerGetter.CurrExecNameCuda = config.MinerGetter.UbuntuSettings.ExecutableNameCuda
-		config.MinerGetter.ExecNamePref = "./"
-	case config.OSType.Win:
-		config.MinerGetter.CurrExecNameOpenCL = config.MinerGetter.WinSettings.ExecutableName
-		config.MinerGetter.CurrExecNameCuda = config.MinerGetter.WinSettings.ExecutableNameCuda
-		config.MinerGetter.ExecNamePref = ""
-	case config.OSType.Macos:
-		config.MinerGetter.CurrExecNameOpenCL = config.MinerGetter.MacSettings.ExecutableName
-		config.MinerGetter.CurrExecNameCuda = config.MinerGetter.MacSettings.ExecutableNameCuda
-		config.MinerGetter.ExecNamePref = "./"
+	if config.ServerSettings.Worker != "" {
+		mlog.LogInfo("Worker name: " + config.ServerSettings.Worker)
 	}
+	mlog.LogInfo("Using data directory: " + config.Paths.DataDir)
 
 	for {
 		if ok := api.Auth(); ok {
@@ -104,71 +301,27 @@ func InitProgram() []gpuwrk.GPUstruct {
 		time.Sleep(time.Second * 5)
 	}
 
+	resolveMinerDir()
 	getminer.GetMiner()
 
-	var allGpus []gpuwrk.GPUstruct
-	knownModels := map[string]struct{}{}
-
-	if config.MinerGetter.CurrExecNameCuda != "" {
-		if gpusArray := gpuwrk.SearchGpusCuda(filepath.Join(
-			config.MinerGetter.ExecNamePref,
-			config.MinerGetter.MinerDirectory,
-			config.MinerGetter.CurrExecNameCuda,
-		)); len(gpusArray) > 0 {
-			for _, gpu := range gpusArray {
-				allGpus = append(allGpus, gpuwrk.GPUstruct{
-					GpuId:      gpu.GpuId,
-					Model:      gpu.Model,
-					PlatformId: gpu.PlatformId,
-					StartPath: filepath.Join(
-						config.MinerGetter.ExecNamePref,
-						config.MinerGetter.MinerDirectory,
-						config.MinerGetter.CurrExecNameCuda,
-					),
-				})
-
-				smRegex := regexp.MustCompile(`^(SM \d\.\d )?(.+)`)
-				matches := smRegex.FindAllStringSubmatch(gpu.Model, -1)
-				if len(matches) == 1 && len(matches[0]) == 3 {
-					knownModels[matches[0][2]] = struct{}{}
-				} else {
-					knownModels[gpu.Model] = struct{}{}
-				}
-			}
+	gpus, _ := discoverOrExit()
+	for _, gpu := range gpus {
+		if gpu.Excluded != "" {
+			mlog.LogInfo("Skipping " + gpu.Model + " (" + gpu.Backend + "): " + gpu.Excluded)
 		}
 	}
 
-	if config.MinerGetter.CurrExecNameOpenCL != "" {
-		if gpusArray := gpuwrk.SearchGpusOpenCL(filepath.Join(
-			config.MinerGetter.ExecNamePref,
-			config.MinerGetter.MinerDirectory,
-			config.MinerGetter.CurrExecNameOpenCL,
-		)); len(gpusArray) > 0 {
-			for _, gpu := range gpusArray {
-				if _, ok := knownModels[gpu.Model]; ok {
-					continue
-				}
-
-				allGpus = append(allGpus, gpuwrk.GPUstruct{
-					GpuId:      gpu.GpuId,
-					Model:      gpu.Model,
-					PlatformId: gpu.PlatformId,
-					StartPath: filepath.Join(
-						config.MinerGetter.ExecNamePref,
-						config.MinerGetter.MinerDirectory,
-						config.MinerGetter.CurrExecNameOpenCL,
-					),
-				})
-			}
-		}
-	}
-	// else if gpusArray =
-
+	allGpus := gpuwrk.Selected(gpus)
 	if len(allGpus) < 1 {
-		log.Fatal("Gpus Not Found")
+		mlog.LogFatal("Gpus Not Found; run with -discover-only to see why, or list the devices in the -config file")
 		// return gpusArray, errors.New("no any GPUs found")
 	}
 
+	if err := gpuwrk.ResolveGpuOverrides(allGpus); err != nil {
+		mlog.LogFatal(err.Error())
+	}
+	gpuwrk.ApplyMinerOverrides(allGpus)
+
 	mlog.LogPass()
 	gpuwrk.LogGpuList(allGpus)
 	mlog.LogInfo(fmt.Sprintf("Launching the mining processes on %d GPUs", len(allGpus)))
@@ -0,0 +1,142 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package initp
+
+import (
+	"miningPoolCli/config"
+	"miningPoolCli/utils/getminer"
+	"miningPoolCli/utils/mlog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+const appDirName = "miningPoolCli"
+
+// xdgDataHome returns $XDG_DATA_HOME/miningPoolCli (~/.local/share/miningPoolCli
+// by default) on linux and an empty string elsewhere
+func xdgDataHome() string {
+	if runtime.GOOS != config.OSType.Linux {
+		return ""
+	}
+
+	if dir := os.Getenv("XDG_DATA_HOME"); filepath.IsAbs(dir) {
+		return filepath.Join(dir, appDirName)
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".local", "share", appDirName)
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// resolveDataDir sets config.Paths.DataDir: -data-dir if given, the XDG
+// data directory on linux, the working directory otherwise
+func resolveDataDir() {
+	dir := config.Paths.DataDir
+	if dir == "" {
+		dir = xdgDataHome()
+	}
+	if dir == "" {
+		if runtime.GOOS == config.OSType.Linux {
+			// e.g. a systemd unit without User=, the working directory is
+			// usually the install directory or /
+			mlog.LogError("HOME and XDG_DATA_HOME are not set, using the working directory " +
+				"for runtime files; set -data-dir")
+		}
+		dir = "."
+	}
+
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		mlog.LogFatal("can't resolve data directory \"" + dir + "\": " + err.Error())
+	}
+	if err := os.MkdirAll(abs, 0755); err != nil {
+		mlog.LogFatal("can't create data directory: " + err.Error())
+	}
+	config.Paths.DataDir = abs
+
+	if config.Paths.LogFile != "" {
+		if err := mlog.SetLogFile(config.DataPath(config.Paths.LogFile)); err != nil {
+			mlog.LogFatal("can't open log file: " + err.Error())
+		}
+	}
+}
+
+// resolveMinerDir sets config.Paths.MinerDir: -miner-dir if given, the
+// downloaded release if there is one, otherwise the first existing
+// "miner_blob" next to the executable, in the working directory or in the
+// XDG data directory. As a last resort the miner is downloaded.
+func resolveMinerDir() {
+	if config.Paths.MinerDir != "" {
+		abs, err := filepath.Abs(config.Paths.MinerDir)
+		if err != nil || !isDir(abs) {
+			mlog.LogFatal("-miner-dir \"" + config.Paths.MinerDir + "\" is not a directory")
+		}
+		config.Paths.MinerDir = abs
+		return
+	}
+
+	if dir := getminer.ManagedMinerDir(); dir != "" {
+		config.Paths.MinerDir = dir
+		mlog.LogInfo("Using miner directory: " + dir)
+		return
+	}
+
+	var candidates []string
+	if exe, err := os.Executable(); err == nil {
+		if exe, err = filepath.EvalSymlinks(exe); err == nil {
+			candidates = append(candidates, filepath.Join(filepath.Dir(exe), config.MinerGetter.MinerDirectory))
+		}
+	}
+	if wd, err := os.Getwd(); err == nil {
+		candidates = append(candidates, filepath.Join(wd, config.MinerGetter.MinerDirectory))
+	}
+	if dir := xdgDataHome(); dir != "" {
+		candidates = append(candidates, filepath.Join(dir, config.MinerGetter.MinerDirectory))
+	}
+
+	for _, dir := range candidates {
+		if isDir(dir) {
+			config.Paths.MinerDir = dir
+			mlog.LogInfo("Using miner directory: " + dir)
+			return
+		}
+	}
+
+	dir, err := getminer.DownloadMiner()
+	if err == nil {
+		config.Paths.MinerDir = dir
+		return
+	}
+	mlog.LogError(err.Error())
+
+	mlog.LogFatal("\"" + config.MinerGetter.MinerDirectory + "\" not found in: " + strings.Join(candidates, ", ") +
+		". It's needed to start miner. Download miner again or set -miner-dir.")
+}
@@ -0,0 +1,82 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package initp
+
+import (
+	"bytes"
+	"miningPoolCli/config"
+	"miningPoolCli/utils/mlog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestResolveDataDir(t *testing.T) {
+	config.Configure()
+	if runtime.GOOS != config.OSType.Linux {
+		t.Skip("XDG directories are only used on linux")
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := t.TempDir()
+
+	tests := []struct {
+		name               string
+		dataDir, xdg, home string
+		want               string
+		warns              bool
+	}{
+		{"-data-dir", filepath.Join(root, "flag"), filepath.Join(root, "xdg"), root, filepath.Join(root, "flag"), false},
+		{"XDG_DATA_HOME", "", filepath.Join(root, "xdg"), root, filepath.Join(root, "xdg", appDirName), false},
+		{"relative XDG_DATA_HOME", "", "xdg", root, filepath.Join(root, ".local", "share", appDirName), false},
+		{"HOME", "", "", root, filepath.Join(root, ".local", "share", appDirName), false},
+		{"working directory", "", "", "", wd, true},
+	}
+
+	var buf bytes.Buffer
+	mlog.SetOutput(&buf)
+	defer mlog.SetOutput(os.Stdout)
+
+	for _, tt := range tests {
+		buf.Reset()
+		t.Setenv("XDG_DATA_HOME", tt.xdg)
+		t.Setenv("HOME", tt.home)
+		config.Paths.DataDir, config.Paths.LogFile = tt.dataDir, ""
+
+		resolveDataDir()
+
+		if config.Paths.DataDir != tt.want {
+			t.Errorf("%s: data directory = %q, want %q", tt.name, config.Paths.DataDir, tt.want)
+		}
+		if !isDir(tt.want) {
+			t.Errorf("%s: %q was not created", tt.name, tt.want)
+		}
+		if warned := strings.Contains(buf.String(), "working directory"); warned != tt.warns {
+			t.Errorf("%s: warned = %v, want %v: %q", tt.name, warned, tt.warns, buf.String())
+		}
+	}
+}
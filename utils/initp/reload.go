@@ -0,0 +1,48 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package initp
+
+import (
+	"flag"
+	"io/ioutil"
+	"miningPoolCli/utils/gpuwrk"
+	"os"
+)
+
+// Reload parses the command line again into fresh settings, which reloads
+// the -config and -hive-config files. Nothing changes if they have errors,
+// otherwise the gpu selection, the miner settings and the thermal guard are
+// replaced; the rest takes a restart. The -gpu-* overrides are resolved
+// against gpus, the current workers. The caller keeps gpu discovery from
+// running meanwhile; the new settings are used by the next one.
+func Reload(gpus []gpuwrk.GPUstruct) error {
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+
+	s, err := parseSettings(fs, os.Args[1:])
+	if err != nil {
+		return err
+	}
+
+	s.ApplyReloadable()
+	return gpuwrk.ResolveGpuOverrides(gpus)
+}
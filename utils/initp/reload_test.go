@@ -0,0 +1,91 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package initp
+
+import (
+	"io/ioutil"
+	"miningPoolCli/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func reloadWith(t *testing.T, args ...string) error {
+	t.Helper()
+	saved := os.Args
+	defer func() { os.Args = saved }()
+	os.Args = append([]string{"miningPoolCli"}, args...)
+	return Reload(nil)
+}
+
+func TestReloadReportsAllErrors(t *testing.T) {
+	config.Configure()
+
+	err := reloadWith(t, "-pool-id=x", "-backend=vulkan", "-stats-format=nope", "-gpu-max-temp=0:hot", "-miner-args=\"-F")
+	if err == nil {
+		t.Fatal("Reload accepted invalid settings")
+	}
+	for _, want := range []string{"vulkan", "nope", "-gpu-max-temp", "-miner-args"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+	if config.GpuSelect.Backend != "auto" || len(config.Thermal.GpuMaxTemp) != 0 {
+		t.Errorf("invalid settings were applied: %+v %+v", config.GpuSelect, config.Thermal)
+	}
+}
+
+func TestReloadHiveConfigTypo(t *testing.T) {
+	config.Configure()
+
+	path := filepath.Join(t.TempDir(), "hive.json")
+	hive := `{"poolId": "x", "url": "stratum+tcp://pool:3333", "user_config": "-max-temp=80 -exclude-gpu=rx"}`
+	if err := ioutil.WriteFile(path, []byte(hive), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := reloadWith(t, "-hive-config="+path)
+	if err == nil || !strings.Contains(err.Error(), "exclude-gpu") {
+		t.Fatalf("Reload = %v, want an error about -exclude-gpu", err)
+	}
+	if config.Thermal.MaxTemp != 0 {
+		t.Error("settings applied despite the error")
+	}
+}
+
+func TestReloadKeepsStartupSettings(t *testing.T) {
+	config.Configure()
+	config.ServerSettings.AuthKey = "started"
+	config.NetSrv.Token = "secret"
+
+	err := reloadWith(t, "-pool-id=other", "-stat-token=changed", "-exclude-gpus=rx 580", "-max-temp=75", "-backend=opencl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.ServerSettings.AuthKey != "started" || config.NetSrv.Token != "secret" {
+		t.Errorf("startup settings replaced: %+v %+v", config.ServerSettings, config.NetSrv)
+	}
+	if len(config.GpuSelect.Exclude) != 1 || config.GpuSelect.Backend != "opencl" || config.Thermal.MaxTemp != 75 {
+		t.Errorf("reloadable settings not applied: %+v %+v", config.GpuSelect, config.Thermal)
+	}
+}
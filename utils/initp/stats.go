@@ -0,0 +1,78 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package initp
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"miningPoolCli/config"
+	"miningPoolCli/utils/export"
+	"miningPoolCli/utils/gpuwrk"
+	"miningPoolCli/utils/mlog"
+	"os"
+	"strings"
+	"time"
+)
+
+// StatsCommand is the "stats" subcommand, and "hive-stats" with the hiveos
+// format: it prints the stats file of the running miner (started with
+// -stats) in the format of a rig OS, format unless -stats-format names
+// another one. Stats older than -max-age mean the miner is down and are
+// reported empty.
+func StatsCommand(args []string, format string) {
+	maxAge := flag.Duration("max-age", export.MaxAge, "")
+	ParseFlags(args)
+
+	mlog.SetOutput(os.Stderr)
+
+	switch len(config.StatsFormats) {
+	case 0:
+	case 1:
+		format = config.StatsFormats[0]
+	default:
+		mlog.LogFatal("stats prints one format, got -stats-format=" + strings.Join(config.StatsFormats, ","))
+	}
+
+	var stats gpuwrk.Stats
+	path := config.DataPath(export.StatsFileName)
+	if data, err := ioutil.ReadFile(path); err != nil {
+		mlog.LogError("can't read stats: " + err.Error())
+	} else {
+		var s gpuwrk.Stats
+		if err := json.Unmarshal(data, &s); err != nil {
+			mlog.LogError("can't parse " + path + ": " + err.Error())
+		} else if export.Stale(s, *maxAge) {
+			age := time.Since(time.Unix(s.Updated, 0))
+			mlog.LogError(path + " is " + age.Round(time.Second).String() + " old, is the miner running?")
+		} else {
+			stats = s
+		}
+	}
+
+	data, err := export.Render(format, stats)
+	if err != nil {
+		mlog.LogFatal(err.Error())
+	}
+	fmt.Println(string(data))
+}
@@ -23,22 +23,137 @@ package mlog
 
 import (
 	"fmt"
+	"io"
 	"miningPoolCli/config"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/go-errors/errors"
 )
 
+// logFile receives an uncolored copy of every message, see SetLogFile
+var logFile io.Writer
+
+// out is where messages are printed, stdout unless changed with SetOutput
+var out io.Writer = os.Stdout
+
+// outMu guards out and logFile, they are swapped while other goroutines log
+var outMu sync.Mutex
+
+// SetOutput prints further messages to w, subcommands whose stdout is read
+// by scripts log to stderr
+func SetOutput(w io.Writer) {
+	outMu.Lock()
+	defer outMu.Unlock()
+	out = w
+}
+
+var atExit []func()
+var atExitMu sync.Mutex
+
+// AtExit registers f to run before the client exits through Exit, a fatal
+// error or a signal, e.g. to restore the terminal
+func AtExit(f func()) {
+	atExitMu.Lock()
+	defer atExitMu.Unlock()
+	atExit = append(atExit, f)
+}
+
+// RunAtExit runs the AtExit functions, for exits that bypass Exit
+func RunAtExit() {
+	atExitMu.Lock()
+	funcs := atExit
+	atExit = nil
+	atExitMu.Unlock()
+
+	for _, f := range funcs {
+		f()
+	}
+}
+
+// Exit runs the AtExit functions and exits with code
+func Exit(code int) {
+	RunAtExit()
+	os.Exit(code)
+}
+
+// ExitOnSignal makes SIGINT and SIGTERM exit through Exit, with the usual
+// 128 + signal number code
+func ExitOnSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		LogInfo("Received " + sig.String() + ", exiting")
+		code := 1
+		if n, ok := sig.(syscall.Signal); ok {
+			code = 128 + int(n)
+		}
+		Exit(code)
+	}()
+}
+
+// SetLogFile appends all further log messages to the file at path
+func SetLogFile(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	outMu.Lock()
+	defer outMu.Unlock()
+	if c, ok := logFile.(io.Closer); ok {
+		c.Close()
+	}
+	logFile = f
+	return nil
+}
+
+// recentSize messages are kept for the dashboard, see Recent
+const recentSize = 200
+
+var recent struct {
+	sync.Mutex
+	lines []string
+}
+
+// Recent returns up to n of the last messages, oldest first
+func Recent(n int) []string {
+	recent.Lock()
+	defer recent.Unlock()
+
+	if n <= 0 || n > len(recent.lines) {
+		n = len(recent.lines)
+	}
+	return append([]string{}, recent.lines[len(recent.lines)-n:]...)
+}
+
 func colorize(message string, color string) {
+	recent.Lock()
+	recent.lines = append(recent.lines, message)
+	if len(recent.lines) > recentSize {
+		recent.lines = recent.lines[len(recent.lines)-recentSize:]
+	}
+	recent.Unlock()
+
+	outMu.Lock()
+	defer outMu.Unlock()
+
+	if logFile != nil {
+		fmt.Fprint(logFile, message+"\n")
+	}
+
 	switch config.OS.OperatingSystem {
 	case config.OSType.Linux:
-		fmt.Print(color, message, config.Colors.ColorReset+"\n")
+		fmt.Fprint(out, color, message, config.Colors.ColorReset+"\n")
 	case config.OSType.Win:
 		// windows not support unix colorize system
-		fmt.Print(message + "\n")
+		fmt.Fprint(out, message+"\n")
 	default:
-		fmt.Print(message + "\n")
+		fmt.Fprint(out, message+"\n")
 	}
 }
 
@@ -64,11 +179,13 @@ func LogError(errMsg string) {
 }
 
 func LogFatal(errMsg string) {
+	RunAtExit()
 	colorize(getNowTimeAsString()+"[--!--] "+"FATAL ERROR: "+errMsg, config.Colors.ColorRed)
 	os.Exit(1)
 }
 
 func LogFatalStackError(err error) {
+	RunAtExit()
 	colorize(
 		getNowTimeAsString()+"[--!--] "+"FATAL ERROR: "+errors.Wrap(err, 1).ErrorStack(),
 		config.Colors.ColorRed,
@@ -77,5 +194,7 @@ func LogFatalStackError(err error) {
 }
 
 func LogPass() {
-	fmt.Println("")
+	outMu.Lock()
+	defer outMu.Unlock()
+	fmt.Fprintln(out, "")
 }
@@ -0,0 +1,124 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package pci
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	VendorNvidia = "0x10de"
+	VendorAMD    = "0x1002"
+	VendorIntel  = "0x8086"
+)
+
+var busIdPat = regexp.MustCompile(`^(?:([0-9a-f]{4,8}):)?([0-9a-f]{2}):([0-9a-f]{2})\.([0-7])$`)
+
+// NormalizeBusId returns the PCI bus id in the "0000:01:00.0" form, or "" if
+// s is not a bus id. nvidia-smi prints an 8 digit domain, "00000000:01:00.0".
+func NormalizeBusId(s string) string {
+	m := busIdPat.FindStringSubmatch(strings.ToLower(strings.TrimSpace(s)))
+	if m == nil {
+		return ""
+	}
+
+	domain := "0000"
+	if m[1] != "" {
+		domain = m[1][len(m[1])-4:]
+	}
+	return domain + ":" + m[2] + ":" + m[3] + "." + m[4]
+}
+
+// Device is a display controller found in sysfs
+type Device struct {
+	BusId  string // "0000:01:00.0"
+	Vendor string // "0x10de"
+}
+
+func readAttr(dir, name string) string {
+	data, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(string(data)))
+}
+
+// DisplayDevices lists the VGA / 3D controllers in sysRoot/bus/pci/devices,
+// sorted by bus id. sysRoot is "/sys" except in tests.
+func DisplayDevices(sysRoot string) ([]Device, error) {
+	devicesDir := filepath.Join(sysRoot, "bus", "pci", "devices")
+	entries, err := ioutil.ReadDir(devicesDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var devices []Device
+	for _, entry := range entries {
+		dir := filepath.Join(devicesDir, entry.Name())
+		// class 0x03xxxx: display controller
+		if !strings.HasPrefix(readAttr(dir, "class"), "0x03") {
+			continue
+		}
+		devices = append(devices, Device{
+			BusId:  strings.ToLower(entry.Name()),
+			Vendor: readAttr(dir, "vendor"),
+		})
+	}
+
+	sort.Slice(devices, func(i, j int) bool { return devices[i].BusId < devices[j].BusId })
+	return devices, nil
+}
+
+// ByVendor returns the devices of one vendor, in bus id order
+func ByVendor(devices []Device, vendor string) []Device {
+	var res []Device
+	for _, d := range devices {
+		if d.Vendor == vendor {
+			res = append(res, d)
+		}
+	}
+	return res
+}
+
+// VendorOfModel guesses the PCI vendor from a device name reported by the
+// miner, "" if it can't be told
+func VendorOfModel(model string) string {
+	model = strings.ToLower(model)
+	for _, s := range []string{"nvidia", "geforce", "quadro", "tesla", "rtx", "gtx"} {
+		if strings.Contains(model, s) {
+			return VendorNvidia
+		}
+	}
+	if strings.Contains(model, "intel") {
+		return VendorIntel
+	}
+	for _, s := range []string{"amd", "radeon", "gfx", "ellesmere", "baffin", "polaris", "vega", "navi", "fiji", "tonga"} {
+		if strings.Contains(model, s) {
+			return VendorAMD
+		}
+	}
+	return ""
+}
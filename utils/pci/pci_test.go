@@ -0,0 +1,110 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package pci
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNormalizeBusId(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"0000:01:00.0", "0000:01:00.0"},
+		{"00000000:0A:00.0", "0000:0a:00.0"},
+		{"01:00.0", "0000:01:00.0"},
+		{" 0001:02:00.1\n", "0001:02:00.1"},
+		{"01:00.8", ""},
+		{"1:00.0", ""},
+		{"gpu0", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeBusId(tt.in); got != tt.want {
+			t.Errorf("NormalizeBusId(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDisplayDevices(t *testing.T) {
+	root := t.TempDir()
+	for _, d := range []struct{ bus, class, vendor string }{
+		{"0000:03:00.0", "0x030000", "0x10DE"},
+		{"0000:00:14.0", "0x0c0330", "0x8086"}, // usb controller
+		{"0000:01:00.0", "0x030200", "0x10de"},
+		{"0000:02:00.0", "0x038000", "0x1002"},
+	} {
+		dir := filepath.Join(root, "bus", "pci", "devices", d.bus)
+		os.MkdirAll(dir, 0755)
+		ioutil.WriteFile(filepath.Join(dir, "class"), []byte(d.class+"\n"), 0644)
+		ioutil.WriteFile(filepath.Join(dir, "vendor"), []byte(d.vendor+"\n"), 0644)
+	}
+
+	devices, err := DisplayDevices(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Device{
+		{"0000:01:00.0", VendorNvidia},
+		{"0000:02:00.0", VendorAMD},
+		{"0000:03:00.0", VendorNvidia},
+	}
+	if len(devices) != len(want) {
+		t.Fatalf("devices = %v, want %v", devices, want)
+	}
+	for i := range want {
+		if devices[i] != want[i] {
+			t.Errorf("devices[%d] = %v, want %v", i, devices[i], want[i])
+		}
+	}
+
+	if nvidia := ByVendor(devices, VendorNvidia); len(nvidia) != 2 || nvidia[1].BusId != "0000:03:00.0" {
+		t.Errorf("ByVendor(nvidia) = %v", nvidia)
+	}
+	if _, err := DisplayDevices(filepath.Join(root, "missing")); err == nil {
+		t.Error("no error without sysfs")
+	}
+}
+
+func TestVendorOfModel(t *testing.T) {
+	tests := []struct {
+		model, want string
+	}{
+		{"NVIDIA GeForce RTX 3060", VendorNvidia},
+		{"Tesla T4", VendorNvidia},
+		{"Ellesmere", VendorAMD},
+		{"AMD Radeon RX 6800", VendorAMD},
+		{"gfx1030", VendorAMD},
+		{"Intel(R) UHD Graphics 630", VendorIntel},
+		{"pthread-cpu", ""},
+	}
+
+	for _, tt := range tests {
+		if got := VendorOfModel(tt.model); got != tt.want {
+			t.Errorf("VendorOfModel(%q) = %q, want %q", tt.model, got, tt.want)
+		}
+	}
+}
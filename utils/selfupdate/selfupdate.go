@@ -0,0 +1,195 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package selfupdate
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"miningPoolCli/config"
+	"miningPoolCli/utils/download"
+	"miningPoolCli/utils/helpers"
+	"miningPoolCli/utils/mlog"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// Feed describes the latest miningPoolCli release:
+//
+//	{"version": "3.2.0", "assets": [{"os": "linux", "arch": "amd64",
+//	  "url": "https://.../miningPoolCli-linux-amd64", "sha256": "...",
+//	  "signature": "<base64 ed25519 signature of the binary>"}]}
+//
+// The feed itself is signed too, with a detached signature at FeedURL +
+// ".sig", so the version can't be relabeled to force a downgrade.
+type Feed struct {
+	Version string  `json:"version"`
+	Assets  []Asset `json:"assets"`
+}
+
+type Asset struct {
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+	URL       string `json:"url"`
+	SHA256    string `json:"sha256"`
+	Signature string `json:"signature"`
+}
+
+type Updater struct {
+	FeedURL        string
+	PublicKey      ed25519.PublicKey
+	CurrentVersion string
+	OS, Arch       string
+	Executable     string // the binary to replace
+	Client         *http.Client
+}
+
+// New returns an Updater for the running binary configured by -update-feed
+// and -update-key
+func New() (*Updater, error) {
+	if config.UpdateSettings.PublicKey == "" {
+		return nil, errors.New("-update-key is not set, self-update is disabled")
+	}
+	key, err := download.ParsePublicKey(config.UpdateSettings.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return nil, err
+	}
+
+	return &Updater{
+		FeedURL:        config.UpdateSettings.FeedURL,
+		PublicKey:      key,
+		CurrentVersion: config.BuildVersion,
+		OS:             config.OS.OperatingSystem,
+		Arch:           config.OS.Architecture,
+		Executable:     exe,
+		Client:         download.DefaultClient,
+	}, nil
+}
+
+// Check fetches the feed and returns the asset for this OS/arch if the feed
+// version is newer than the running one
+func (u *Updater) Check() (Feed, *Asset, error) {
+	var feed Feed
+
+	body, err := download.Get(u.Client, u.FeedURL)
+	if err != nil {
+		return feed, nil, err
+	}
+	sig, err := download.Get(u.Client, u.FeedURL+".sig")
+	if err != nil {
+		return feed, nil, errors.New("can't get update feed signature: " + err.Error())
+	}
+	if err := download.VerifySignature(u.PublicKey, body, string(sig)); err != nil {
+		return feed, nil, errors.New("update feed " + u.FeedURL + ": " + err.Error())
+	}
+	if err := json.Unmarshal(body, &feed); err != nil {
+		return feed, nil, errors.New("can't parse update feed: " + err.Error())
+	}
+
+	if helpers.CompareVersions(feed.Version, u.CurrentVersion) <= 0 {
+		return feed, nil, nil
+	}
+
+	for i := range feed.Assets {
+		if feed.Assets[i].OS == u.OS && feed.Assets[i].Arch == u.Arch {
+			return feed, &feed.Assets[i], nil
+		}
+	}
+	return feed, nil, errors.New("release " + feed.Version + " has no binary for " + u.OS + "/" + u.Arch)
+}
+
+// Apply downloads the asset next to the executable, verifies its checksum
+// and signature and replaces the executable with it
+func (u *Updater) Apply(asset Asset) error {
+	newExe := u.Executable + ".new"
+	defer os.Remove(newExe)
+
+	if err := download.File(u.Client, asset.URL, newExe); err != nil {
+		return err
+	}
+	if err := download.VerifySHA256(newExe, asset.SHA256); err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadFile(newExe)
+	if err != nil {
+		return err
+	}
+	if err := download.VerifySignature(u.PublicKey, data, asset.Signature); err != nil {
+		return errors.New("update " + asset.URL + ": " + err.Error())
+	}
+
+	if err := os.Chmod(newExe, 0755); err != nil {
+		return err
+	}
+	return replaceExecutable(u.Executable, newExe)
+}
+
+// Update checks the feed and installs a newer release. It returns the
+// installed version or "" if the running one is up to date.
+func (u *Updater) Update() (string, error) {
+	feed, asset, err := u.Check()
+	if err != nil || asset == nil {
+		return "", err
+	}
+
+	mlog.LogInfo("Updating miningPoolCli " + u.CurrentVersion + " -> " + feed.Version)
+	if err := u.Apply(*asset); err != nil {
+		return "", err
+	}
+	return feed.Version, nil
+}
+
+// Cleanup removes the binary left by a previous update on windows
+func Cleanup() {
+	if exe, err := os.Executable(); err == nil {
+		os.Remove(exe + ".old")
+	}
+}
+
+// RunCommand is the "update" subcommand
+func RunCommand() {
+	u, err := New()
+	if err != nil {
+		mlog.LogFatal(err.Error())
+	}
+
+	version, err := u.Update()
+	if err != nil {
+		mlog.LogFatal("Update failed: " + err.Error())
+	}
+	if version == "" {
+		mlog.LogOk("miningPoolCli " + config.BuildVersion + " is up to date")
+		return
+	}
+	mlog.LogOk("Updated to miningPoolCli " + version + ", restart the client to use it")
+}
@@ -0,0 +1,203 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package selfupdate
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// feedServer serves a release feed signed with its own key
+type feedServer struct {
+	*httptest.Server
+	key   ed25519.PrivateKey
+	files map[string][]byte
+}
+
+func newFeedServer(t *testing.T) *feedServer {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &feedServer{key: key, files: map[string][]byte{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := s.files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *feedServer) sign(data []byte) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(s.key, data))
+}
+
+// publish makes version the feed's release with a binary for linux/amd64
+func (s *feedServer) publish(version string, binary []byte) {
+	sum := sha256.Sum256(binary)
+	s.files["/miningPoolCli-linux-amd64"] = binary
+
+	feed, _ := json.Marshal(Feed{
+		Version: version,
+		Assets: []Asset{{
+			OS:        "linux",
+			Arch:      "amd64",
+			URL:       s.URL + "/miningPoolCli-linux-amd64",
+			SHA256:    hex.EncodeToString(sum[:]),
+			Signature: s.sign(binary),
+		}},
+	})
+	s.files["/feed.json"] = feed
+	s.files["/feed.json.sig"] = []byte(s.sign(feed))
+}
+
+func (s *feedServer) updater(t *testing.T) *Updater {
+	exe := filepath.Join(t.TempDir(), "miningPoolCli")
+	if err := ioutil.WriteFile(exe, []byte("current"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return &Updater{
+		FeedURL:        s.URL + "/feed.json",
+		PublicKey:      s.key.Public().(ed25519.PublicKey),
+		CurrentVersion: "3.1.0",
+		OS:             "linux",
+		Arch:           "amd64",
+		Executable:     exe,
+		Client:         s.Client(),
+	}
+}
+
+func executable(t *testing.T, u *Updater) string {
+	data, err := ioutil.ReadFile(u.Executable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestUpdateNewerVersion(t *testing.T) {
+	s := newFeedServer(t)
+	s.publish("3.2.0", []byte("new"))
+	u := s.updater(t)
+
+	version, err := u.Update()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != "3.2.0" || executable(t, u) != "new" {
+		t.Fatalf("version = %q, executable = %q", version, executable(t, u))
+	}
+}
+
+func TestUpdateUpToDate(t *testing.T) {
+	for _, version := range []string{"3.1.0", "3.0.9", "3.1.0-rc2"} {
+		s := newFeedServer(t)
+		s.publish(version, []byte("other"))
+		u := s.updater(t)
+
+		installed, err := u.Update()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if installed != "" || executable(t, u) != "current" {
+			t.Fatalf("feed %s: installed %q, executable = %q", version, installed, executable(t, u))
+		}
+	}
+}
+
+func TestUpdateBadSignature(t *testing.T) {
+	_, other, _ := ed25519.GenerateKey(rand.Reader)
+
+	tests := []struct {
+		name   string
+		tamper func(s *feedServer)
+		err    string
+	}{
+		{"feed signed by another key", func(s *feedServer) {
+			s.files["/feed.json.sig"] = []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(other, s.files["/feed.json"])))
+		}, "verification failed"},
+		{"feed without signature", func(s *feedServer) {
+			delete(s.files, "/feed.json.sig")
+		}, "feed signature"},
+		{"relabeled version", func(s *feedServer) {
+			s.files["/feed.json"] = []byte(strings.Replace(string(s.files["/feed.json"]), "3.2.0", "9.9.9", 1))
+		}, "verification failed"},
+		{"binary signed by another key", func(s *feedServer) {
+			var feed Feed
+			json.Unmarshal(s.files["/feed.json"], &feed)
+			feed.Assets[0].Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(other, []byte("new")))
+			s.files["/feed.json"], _ = json.Marshal(feed)
+			s.files["/feed.json.sig"] = []byte(s.sign(s.files["/feed.json"]))
+		}, "verification failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newFeedServer(t)
+			s.publish("3.2.0", []byte("new"))
+			tt.tamper(s)
+			u := s.updater(t)
+
+			if _, err := u.Update(); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("err = %v, want %q", err, tt.err)
+			}
+			if executable(t, u) != "current" {
+				t.Fatal("executable replaced")
+			}
+		})
+	}
+}
+
+func TestUpdateMissingAsset(t *testing.T) {
+	s := newFeedServer(t)
+	s.publish("3.2.0", []byte("new"))
+	u := s.updater(t)
+	u.Arch = "arm64"
+
+	if _, err := u.Update(); err == nil || !strings.Contains(err.Error(), "no binary for linux/arm64") {
+		t.Fatalf("err = %v", err)
+	}
+
+	// listed but not downloadable
+	u.Arch = "amd64"
+	delete(s.files, "/miningPoolCli-linux-amd64")
+	if _, err := u.Update(); err == nil {
+		t.Fatal("update without the binary")
+	}
+	if executable(t, u) != "current" {
+		t.Fatal("executable replaced")
+	}
+}
@@ -0,0 +1,41 @@
+//go:build !windows
+// +build !windows
+
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package selfupdate
+
+import (
+	"os"
+	"syscall"
+)
+
+// replaceExecutable renames newExe over exe, the running process keeps the
+// old inode
+func replaceExecutable(exe, newExe string) error {
+	return os.Rename(newExe, exe)
+}
+
+// Reexec replaces the process with the binary at exe and the same arguments
+func Reexec(exe string) error {
+	return syscall.Exec(exe, os.Args, os.Environ())
+}
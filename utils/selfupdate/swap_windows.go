@@ -0,0 +1,52 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package selfupdate
+
+import (
+	"os"
+	"os/exec"
+)
+
+// replaceExecutable moves the running exe aside (windows can't overwrite
+// it) and puts newExe in its place, the old one is removed by Cleanup
+func replaceExecutable(exe, newExe string) error {
+	os.Remove(exe + ".old")
+	if err := os.Rename(exe, exe+".old"); err != nil {
+		return err
+	}
+	if err := os.Rename(newExe, exe); err != nil {
+		os.Rename(exe+".old", exe)
+		return err
+	}
+	return nil
+}
+
+// Reexec starts exe with the same arguments and exits
+func Reexec(exe string) error {
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	os.Exit(0)
+	return nil
+}
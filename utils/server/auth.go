@@ -0,0 +1,205 @@
+package server
+
+import (
+	"crypto/subtle"
+	"errors"
+	"miningPoolCli/config"
+	"miningPoolCli/utils/mlog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// access guards every endpoint: clients that are not on loopback (or the
+// unix socket) must be in -stat-allow, the Host header must name the server
+// and the token or basic auth must match when one is set
+type access struct {
+	allowed   []*net.IPNet
+	hostNames map[string]bool // besides localhost and IP literals
+}
+
+// newAccess takes the -stat-allow hosts and the -stat-listen addresses,
+// whose host names are accepted in the Host header
+func newAccess(hosts []string, listenAddrs []string) (*access, error) {
+	a := &access{hostNames: map[string]bool{"localhost": true}}
+	for _, addr := range listenAddrs {
+		if strings.HasPrefix(addr, unixPrefix) {
+			continue
+		}
+		if host, _, err := net.SplitHostPort(addr); err == nil && host != "" {
+			a.hostNames[strings.ToLower(host)] = true
+		}
+	}
+	for _, host := range hosts {
+		if !strings.Contains(host, "/") {
+			if strings.Contains(host, ":") {
+				host += "/128"
+			} else {
+				host += "/32"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(host)
+		if err != nil {
+			return nil, errors.New("invalid -stat-allow \"" + host + "\"")
+		}
+		a.allowed = append(a.allowed, ipNet)
+	}
+	return a, nil
+}
+
+func (a *access) clientAllowed(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return true // unix socket, guarded by the file permissions
+	}
+	return a.ipAllowed(net.ParseIP(host))
+}
+
+// connAllowed checks the client of a TCP connection like clientAllowed
+func (a *access) connAllowed(conn net.Conn) bool {
+	tcpAddr, ok := conn.RemoteAddr().(*net.TCPAddr)
+	return ok && a.ipAllowed(tcpAddr.IP)
+}
+
+// ipAllowed tells whether the client is on loopback or in -stat-allow
+func (a *access) ipAllowed(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	if ip.IsLoopback() {
+		return true
+	}
+	for _, ipNet := range a.allowed {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// hostAllowed checks the Host header against DNS rebinding: a page on an
+// attacker's domain that resolves to the rig would otherwise pass as same
+// origin. Unix socket clients are not browsers.
+func (a *access) hostAllowed(r *http.Request) bool {
+	if _, _, err := net.SplitHostPort(r.RemoteAddr); err != nil || r.Host == "" {
+		return true
+	}
+
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if net.ParseIP(host) != nil {
+		return true
+	}
+	return a.hostNames[strings.TrimSuffix(strings.ToLower(host), ".")]
+}
+
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+func authorized(r *http.Request) bool {
+	token, basic := config.NetSrv.Token, config.NetSrv.BasicAuth
+	if token == "" && basic == "" {
+		return true
+	}
+
+	if token != "" {
+		if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") && equal(strings.TrimPrefix(h, "Bearer "), token) {
+			return true
+		}
+	}
+	if basic != "" {
+		if user, password, ok := r.BasicAuth(); ok && equal(user+":"+password, basic) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *access) protect(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.clientAllowed(r) {
+			w.Header().Set("Content-Type", "application/json")
+			http.Error(w, errJson.Forbidden, http.StatusForbidden)
+			return
+		}
+		if !a.hostAllowed(r) {
+			mlog.LogError("Rejected request for host \"" + r.Host + "\" from " + r.RemoteAddr + ", see -stat-listen")
+			w.Header().Set("Content-Type", "application/json")
+			http.Error(w, errJson.Forbidden, http.StatusForbidden)
+			return
+		}
+		if !authorized(r) {
+			if config.NetSrv.BasicAuth != "" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="miningPoolCli"`)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			http.Error(w, errJson.Unauthorized, http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// sameOrigin rejects requests a web page on another site could make from
+// the rig's browser: those carry a foreign Origin or Sec-Fetch-Site, and
+// can only have the form content types without a CORS preflight
+func sameOrigin(r *http.Request) bool {
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "", "same-origin", "none":
+	default:
+		return false
+	}
+
+	if origin := r.Header.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		if err != nil || u.Host != r.Host {
+			return false
+		}
+	}
+
+	contentType := strings.ToLower(r.Header.Get("Content-Type"))
+	for _, formType := range []string{"application/x-www-form-urlencoded", "multipart/form-data", "text/plain"} {
+		if strings.HasPrefix(contentType, formType) {
+			return false
+		}
+	}
+	return true
+}
+
+// mutating allows only same-origin POST requests to endpoints that change
+// the state of the miner
+func mutating(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Method != "POST" {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, errJson.MethodNotAllowed, http.StatusMethodNotAllowed)
+			return
+		}
+		if !sameOrigin(r) {
+			mlog.LogError("Rejected cross-site " + r.URL.Path + " request from " + r.RemoteAddr)
+			http.Error(w, errJson.Forbidden, http.StatusForbidden)
+			return
+		}
+		h(w, r)
+	}
+}
+
+func isLoopback(addr net.Addr) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	return ok && tcpAddr.IP.IsLoopback()
+}
+
+// isLoopbackListener tells if only local clients can reach the listener
+func isLoopbackListener(l net.Listener) bool {
+	if l.Addr().Network() == "unix" {
+		return true
+	}
+	return isLoopback(l.Addr())
+}
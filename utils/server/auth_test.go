@@ -0,0 +1,66 @@
+package server
+
+import (
+	"net"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHostAllowed(t *testing.T) {
+	a, err := newAccess(nil, []string{"127.0.0.1:0", "Rig.lan:8080", ":9090", "unix:/run/miningPoolCli.sock"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		host, remote string
+		want         bool
+	}{
+		{"127.0.0.1:8080", "127.0.0.1:5000", true},
+		{"[::1]:8080", "[::1]:5000", true},
+		{"192.168.1.10", "192.168.1.20:5000", true},
+		{"localhost:8080", "127.0.0.1:5000", true},
+		{"LOCALHOST.", "127.0.0.1:5000", true},
+		{"rig.lan:8080", "192.168.1.20:5000", true},
+		{"attacker.example:8080", "127.0.0.1:5000", false},
+		{"rig.lan.attacker.example", "127.0.0.1:5000", false},
+		{"attacker.example", "@", true}, // unix socket
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/stat", nil)
+		r.Host = tt.host
+		r.RemoteAddr = tt.remote
+		if got := a.hostAllowed(r); got != tt.want {
+			t.Errorf("hostAllowed(Host %q from %q) = %v, want %v", tt.host, tt.remote, got, tt.want)
+		}
+	}
+}
+
+func TestIpAllowed(t *testing.T) {
+	a, err := newAccess([]string{"192.168.1.0/24", "10.0.0.5", "fd00::1"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"127.0.0.1", true},
+		{"::1", true},
+		{"192.168.1.77", true},
+		{"192.168.2.1", false},
+		{"10.0.0.5", true},
+		{"10.0.0.6", false},
+		{"fd00::1", true},
+		{"fd00::2", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := a.ipAllowed(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("ipAllowed(%q) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"miningPoolCli/config"
+	"miningPoolCli/utils/gpuwrk"
+	"miningPoolCli/utils/mlog"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// httpRequestLine matches "POST / HTTP/1.1"
+var httpRequestLine = regexp.MustCompile(`^[A-Z]+ \S+ HTTP/\d`)
+
+type claymoreRequest struct {
+	Id     interface{} `json:"id"`
+	Method string      `json:"method"`
+	Psw    string      `json:"psw"`
+}
+
+type claymoreResponse struct {
+	Id      interface{} `json:"id"`
+	JsonRPC string      `json:"jsonrpc"`
+	Result  interface{} `json:"result"`
+	Error   interface{} `json:"error"`
+}
+
+// ClaymoreAPI serves the Claymore / ethminer miner_getstat1, miner_restart
+// and miner_reboot JSON-RPC methods on TCP for third-party monitoring.
+// Clients must be on loopback or in -stat-allow like for the stat server.
+// The control methods need -claymore-password, even on loopback: any local
+// process or a web page could call them otherwise.
+func ClaymoreAPI(addr string, gpuData *[]*gpuwrk.GpuGoroutine, control Control) {
+	access, err := newAccess(config.NetSrv.AllowedHosts, nil)
+	if err != nil {
+		mlog.LogFatal(err.Error())
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		mlog.LogFatal("can't listen on -claymore-api " + addr + ": " + err.Error())
+	}
+	mlog.LogInfo("Claymore API at: " + listener.Addr().String())
+
+	allowControl := config.NetSrv.ClaymorePassword != ""
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			mlog.LogError("Claymore API: " + err.Error())
+			time.Sleep(time.Second)
+			continue
+		}
+		if !access.connAllowed(conn) {
+			mlog.LogError("Claymore API: rejected connection from " + conn.RemoteAddr().String() + ", see -stat-allow")
+			conn.Close()
+			continue
+		}
+		go serveClaymore(conn, gpuData, control, allowControl)
+	}
+}
+
+func serveClaymore(conn net.Conn, gpuData *[]*gpuwrk.GpuGoroutine, control Control, allowControl bool) {
+	defer conn.Close()
+
+	// clients send one request per line, most of them one per connection
+	scanner := bufio.NewScanner(conn)
+	for {
+		conn.SetDeadline(time.Now().Add(10 * time.Second))
+		if !scanner.Scan() {
+			return
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if httpRequestLine.MatchString(line) {
+			// a browser, e.g. a page posting to it cross-site
+			return
+		}
+
+		var req claymoreRequest
+		resp := claymoreResponse{JsonRPC: "2.0"}
+		if err := json.Unmarshal([]byte(line), &req); err != nil {
+			resp.Error = "invalid request"
+		} else {
+			resp.Id = req.Id
+			resp.Result, resp.Error = claymoreCall(req, gpuData, control, allowControl)
+		}
+
+		data, _ := json.Marshal(resp)
+		if _, err := conn.Write(append(data, '\n')); err != nil {
+			return
+		}
+	}
+}
+
+func claymoreCall(req claymoreRequest, gpuData *[]*gpuwrk.GpuGoroutine, control Control, allowControl bool) (interface{}, interface{}) {
+	if config.NetSrv.ClaymorePassword != "" && !equal(req.Psw, config.NetSrv.ClaymorePassword) {
+		return nil, "invalid password"
+	}
+
+	switch req.Method {
+	case "miner_getstat1":
+		return claymoreStat(gpuData), nil
+	case "miner_restart", "miner_reboot":
+		if !allowControl {
+			return nil, "control methods need -claymore-password"
+		}
+		mlog.LogInfo("Received " + req.Method + " on the Claymore API")
+		if req.Method == "miner_restart" {
+			go control.Action("restart", -1)
+		} else {
+			// after the response is sent
+			go func() {
+				time.Sleep(100 * time.Millisecond)
+				control.Reboot()
+			}()
+		}
+		return true, nil
+	}
+	return nil, "unknown method"
+}
+
+// claymoreStat is the miner_getstat1 result, hashrates in kH/s:
+//
+//	version, uptime in minutes, "total;accepted;rejected", "gpu0;gpu1",
+//	dual total, dual per gpu, "temp0;fan0;temp1;fan1", pool,
+//	"invalid;pool switches;dual invalid;dual pool switches"
+func claymoreStat(gpuData *[]*gpuwrk.GpuGoroutine) []string {
+	gpuwrk.WorkersMu.RLock()
+	defer gpuwrk.WorkersMu.RUnlock()
+
+	var total, accepted, rejected int
+	var hashrates, dual, sensors []string
+	for _, g := range *gpuData {
+		khs := g.CurrentHashrate * 1000
+		total += khs
+		accepted += g.Accepted
+		rejected += g.Rejected
+		hashrates = append(hashrates, strconv.Itoa(khs))
+		dual = append(dual, "off")
+		sensors = append(sensors, strconv.Itoa(g.Telemetry.Temp), strconv.Itoa(g.Telemetry.Fan))
+	}
+
+	pool := config.ServerSettings.MiningPoolServerURL
+	if u, err := url.Parse(pool); err == nil && u.Host != "" {
+		pool = u.Host
+	}
+
+	return []string{
+		config.BuildVersion + " - miningPoolCli",
+		strconv.FormatInt((time.Now().Unix()-config.StartProgramTimestamp)/60, 10),
+		strconv.Itoa(total) + ";" + strconv.Itoa(accepted) + ";" + strconv.Itoa(rejected),
+		strings.Join(hashrates, ";"),
+		"0;0;0",
+		strings.Join(dual, ";"),
+		strings.Join(sensors, ";"),
+		pool,
+		"0;0;0;0",
+	}
+}
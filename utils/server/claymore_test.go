@@ -0,0 +1,136 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"miningPoolCli/config"
+	"miningPoolCli/utils/gpuwrk"
+	"miningPoolCli/utils/telemetry"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClaymoreStat(t *testing.T) {
+	savedVersion, savedStart, savedPool := config.BuildVersion, config.StartProgramTimestamp, config.ServerSettings.MiningPoolServerURL
+	defer func() {
+		config.BuildVersion, config.StartProgramTimestamp, config.ServerSettings.MiningPoolServerURL = savedVersion, savedStart, savedPool
+	}()
+	config.BuildVersion = "1.0"
+	config.StartProgramTimestamp = time.Now().Unix() - 10*60 - 30
+	config.ServerSettings.MiningPoolServerURL = "https://pool.example:8443/api"
+
+	gpus := []*gpuwrk.GpuGoroutine{
+		{CurrentHashrate: 2, Accepted: 6, Telemetry: telemetry.Reading{Temp: 60, Fan: 70}},
+		{CurrentHashrate: 3, Accepted: 4, Rejected: 1},
+	}
+
+	want := []string{"1.0 - miningPoolCli", "10", "5000;10;1", "2000;3000", "0;0;0", "off;off", "60;70;0;0", "pool.example:8443", "0;0;0;0"}
+	if got := claymoreStat(&gpus); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("claymoreStat =\n %q\nwant\n %q", got, want)
+	}
+}
+
+func TestClaymoreCall(t *testing.T) {
+	saved := config.NetSrv.ClaymorePassword
+	defer func() { config.NetSrv.ClaymorePassword = saved }()
+
+	actions := make(chan string, 1)
+	control := Control{
+		Action: func(action string, gpu int) error { actions <- action; return nil },
+		Reboot: func() { actions <- "reboot" },
+	}
+	var gpus []*gpuwrk.GpuGoroutine
+
+	tests := []struct {
+		name, password, method, psw string
+		wantErr                     string
+		wantAction                  string
+	}{
+		{"stat", "", "miner_getstat1", "", "", ""},
+		{"stat with password", "secret", "miner_getstat1", "secret", "", ""},
+		{"wrong password", "secret", "miner_getstat1", "secre", "invalid password", ""},
+		{"control without password", "", "miner_restart", "", "control methods need -claymore-password", ""},
+		{"restart", "secret", "miner_restart", "secret", "", "restart"},
+		{"reboot", "secret", "miner_reboot", "secret", "", "reboot"},
+		{"reboot, wrong password", "secret", "miner_reboot", "", "invalid password", ""},
+		{"unknown method", "secret", "miner_file", "secret", "unknown method", ""},
+	}
+
+	for _, tt := range tests {
+		config.NetSrv.ClaymorePassword = tt.password
+		req := claymoreRequest{Id: 0, Method: tt.method, Psw: tt.psw}
+
+		_, errMsg := claymoreCall(req, &gpus, control, tt.password != "")
+		if errMsg == nil {
+			errMsg = ""
+		}
+		if errMsg != tt.wantErr {
+			t.Errorf("%s: error %v, want %q", tt.name, errMsg, tt.wantErr)
+		}
+
+		if tt.wantAction != "" {
+			select {
+			case action := <-actions:
+				if action != tt.wantAction {
+					t.Errorf("%s: %s called, want %s", tt.name, action, tt.wantAction)
+				}
+			case <-time.After(time.Second):
+				t.Errorf("%s: %s not called", tt.name, tt.wantAction)
+			}
+		}
+	}
+
+	select {
+	case action := <-actions:
+		t.Errorf("unexpected %s", action)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestServeClaymore(t *testing.T) {
+	saved := config.NetSrv.ClaymorePassword
+	config.NetSrv.ClaymorePassword = ""
+	defer func() { config.NetSrv.ClaymorePassword = saved }()
+
+	client, conn := net.Pipe()
+	defer client.Close()
+	var gpus []*gpuwrk.GpuGoroutine
+	go serveClaymore(conn, &gpus, Control{}, false)
+
+	client.SetDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(client)
+	call := func(line string) claymoreResponse {
+		t.Helper()
+		if _, err := client.Write([]byte(line + "\n")); err != nil {
+			t.Fatal(err)
+		}
+		data, err := reader.ReadBytes('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		var resp claymoreResponse
+		if err := json.Unmarshal(data, &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	// several requests on one connection, the id is echoed
+	if resp := call(`{"id":7,"jsonrpc":"2.0","method":"miner_getstat1"}`); resp.Id != 7.0 || resp.Error != nil || resp.Result == nil {
+		t.Errorf("miner_getstat1 = %+v", resp)
+	}
+	if resp := call(`{"id":8,"method":"miner_restart"}`); resp.Id != 8.0 || resp.Error == nil {
+		t.Errorf("miner_restart = %+v", resp)
+	}
+	if resp := call(`not json`); resp.Error != "invalid request" {
+		t.Errorf("invalid json = %+v", resp)
+	}
+
+	// a browser posting cross-site gets no answer
+	client.Write([]byte("POST / HTTP/1.1\n"))
+	if _, err := reader.ReadBytes('\n'); err == nil {
+		t.Error("HTTP request answered")
+	}
+}
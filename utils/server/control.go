@@ -0,0 +1,63 @@
+package server
+
+import (
+	"encoding/json"
+	"miningPoolCli/utils/mlog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// controlActions are served at "/<action>" for all gpus and at
+// "/gpu/<index>/<action>" for one
+var controlActions = []string{"pause", "resume", "restart", "drain"}
+
+func writeResult(w http.ResponseWriter, err error, failCode int) {
+	resp := struct {
+		Status bool   `json:"status"`
+		Error  string `json:"error,omitempty"`
+	}{Status: err == nil}
+
+	if err != nil {
+		resp.Error = err.Error()
+		w.WriteHeader(failCode)
+	}
+
+	jsonResp, err := json.Marshal(resp)
+	if err != nil {
+		mlog.LogFatalStackError(err)
+	}
+	w.Write(jsonResp)
+}
+
+func actionHandler(control Control, action string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mlog.LogInfo("Received /" + action + " HTTP request")
+		writeResult(w, control.Action(action, -1), http.StatusBadRequest)
+	}
+}
+
+func gpuActionHandler(control Control) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/gpu/"), "/")
+		if len(parts) != 2 {
+			http.NotFound(w, r)
+			return
+		}
+		index, err := strconv.Atoi(parts[0])
+		if err != nil || index < 0 {
+			http.NotFound(w, r)
+			return
+		}
+
+		mlog.LogInfo("Received " + r.URL.Path + " HTTP request")
+		writeResult(w, control.Action(parts[1], index), http.StatusNotFound)
+	}
+}
+
+func reloadHandler(control Control) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mlog.LogInfo("Received /reload HTTP request")
+		writeResult(w, control.Reload(), http.StatusBadRequest)
+	}
+}
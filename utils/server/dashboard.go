@@ -0,0 +1,30 @@
+package server
+
+import (
+	"embed"
+	"io/fs"
+	"miningPoolCli/utils/mlog"
+	"net/http"
+)
+
+//go:embed web
+var webFiles embed.FS
+
+// dashboardHandler serves the web dashboard at "/", it uses the other
+// endpoints of the server
+func dashboardHandler() http.Handler {
+	web, err := fs.Sub(webFiles, "web")
+	if err != nil {
+		mlog.LogFatalStackError(err)
+	}
+	files := http.FileServer(http.FS(web))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" && r.Method != "HEAD" {
+			w.Header().Set("Content-Type", "application/json")
+			http.Error(w, errJson.MethodNotAllowed, http.StatusMethodNotAllowed)
+			return
+		}
+		files.ServeHTTP(w, r)
+	})
+}
@@ -1,37 +1,123 @@
 package server
 
 import (
-	"io/ioutil"
 	"miningPoolCli/config"
+	"miningPoolCli/utils/api"
+	"miningPoolCli/utils/files"
 	"miningPoolCli/utils/gpuwrk"
 	"miningPoolCli/utils/mlog"
 	"net"
 	"net/http"
-	"strconv"
+	"os"
+	"strings"
 )
 
-func Entrypoint(gpuData *[]gpuwrk.GpuGoroutine) {
-	http.HandleFunc("/stat", statHandler(gpuData))
+// Control is provided by main for the endpoints that manage the workers
+type Control struct {
+	Rediscover func() (gpuwrk.RediscoverResult, error)
+	Action     func(action string, gpu int) error // gpu -1 means all of them
+	Reload     func() error                       // reads the config files again
+	Reboot     func()                             // restarts the whole client
+	Tasks      func() []api.Task                  // the pool's current tasks
+}
+
+const unixPrefix = "unix:"
+
+// listen opens a -stat-listen address: "host:port" (port 0 picks a free
+// one) or "unix:/path/to.sock". A stale socket file is replaced.
+func listen(addr string) (net.Listener, error) {
+	if !strings.HasPrefix(addr, unixPrefix) {
+		return net.Listen("tcp", addr)
+	}
+
+	path := strings.TrimPrefix(addr, unixPrefix)
+	if fi, err := os.Stat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	return net.Listen("unix", path)
+}
+
+// listenerAddr is the address clients use, in the -stat-listen form
+func listenerAddr(l net.Listener) string {
+	if l.Addr().Network() == "unix" {
+		return unixPrefix + l.Addr().String()
+	}
+	return l.Addr().String()
+}
+
+func Entrypoint(gpuData *[]*gpuwrk.GpuGoroutine, control Control) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stat", statHandler(gpuData))
+	mux.HandleFunc("/metrics", metricsHandler(gpuData))
+	mux.HandleFunc("/export/", exportHandler)
+	mux.HandleFunc("/events", eventsHandler)
+	mux.HandleFunc("/tasks", tasksHandler(control))
+	mux.HandleFunc("/logs", logsHandler)
+	mux.HandleFunc("/history", historyHandler(gpuData))
+	mux.Handle("/", dashboardHandler())
+	mux.HandleFunc("/rediscover", mutating(rediscoverHandler(control)))
+	for _, action := range controlActions {
+		mux.HandleFunc("/"+action, mutating(actionHandler(control, action)))
+	}
+	mux.HandleFunc("/gpu/", mutating(gpuActionHandler(control)))
+	mux.HandleFunc("/reload", mutating(reloadHandler(control)))
 
 	if config.NetSrv.HandleKill {
-		http.HandleFunc("/kill", killHandler(gpuData))
+		mux.HandleFunc("/kill", mutating(killHandler(gpuData)))
 		mlog.LogInfo("Set kill http handler at /kill")
 	}
 
-	listener, err := net.Listen("tcp", config.NetSrv.Host+":0")
-	if err != nil {
-		mlog.LogError("Failed to get tcp")
-		mlog.LogFatalStackError(err)
+	addrs := config.NetSrv.Listen
+	if len(addrs) == 0 {
+		addrs = []string{config.NetSrv.Host + ":0"}
+	}
+
+	var listeners []net.Listener
+	var hostPorts []string
+	for _, addr := range addrs {
+		listener, err := listen(addr)
+		if err != nil {
+			mlog.LogError("Failed to listen on " + addr)
+			mlog.LogFatalStackError(err)
+		}
+		listeners = append(listeners, listener)
+		hostPorts = append(hostPorts, listenerAddr(listener))
+		mlog.LogInfo("Server at: " + listenerAddr(listener))
+
+		if !isLoopbackListener(listener) {
+			if len(config.NetSrv.AllowedHosts) == 0 {
+				mlog.LogInfo("warn: " + addr + " is not loopback but only local clients are allowed, see -stat-allow")
+			}
+			if config.NetSrv.Token == "" && config.NetSrv.BasicAuth == "" {
+				mlog.LogInfo("warn: " + addr + " is not loopback and the server has no -stat-token / -stat-basic-auth")
+			}
+		}
 	}
-	hostPort := config.NetSrv.Host + ":" + strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
 
-	if err := ioutil.WriteFile(config.NetSrv.HostFileName, []byte(hostPort), 0644); err != nil {
+	// one address per line, the first one is what older readers expect
+	hostFile := config.DataPath(config.NetSrv.HostFileName)
+	if err := files.WriteAtomic(hostFile, []byte(strings.Join(hostPorts, "\n")), 0644); err != nil {
 		mlog.LogFatalStackError(err)
 	}
-	mlog.LogInfo("Server addr saved to: " + config.NetSrv.HostFileName)
+	mlog.LogInfo("Server addr saved to: " + hostFile)
 
-	mlog.LogInfo("Server at: " + hostPort)
-	if err := http.Serve(listener, nil); err != nil {
+	access, err := newAccess(config.NetSrv.AllowedHosts, addrs)
+	if err != nil {
+		mlog.LogFatal(err.Error())
+	}
+
+	srv := &http.Server{Handler: access.protect(mux)}
+	errs := make(chan error)
+	for _, listener := range listeners {
+		go func(l net.Listener) {
+			if config.NetSrv.TLSCert != "" {
+				errs <- srv.ServeTLS(l, config.NetSrv.TLSCert, config.NetSrv.TLSKey)
+			} else {
+				errs <- srv.Serve(l)
+			}
+		}(listener)
+	}
+	if err := <-errs; err != nil {
 		mlog.LogFatalStackError(err)
 	}
 }
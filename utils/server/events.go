@@ -0,0 +1,93 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"miningPoolCli/utils/events"
+	"miningPoolCli/utils/mlog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// keepAliveInterval is how often a comment is sent on an idle stream so
+// proxies don't close it
+const keepAliveInterval = 15 * time.Second
+
+// eventsHandler streams the events as Server-Sent Events. "?types=a,b"
+// filters them, a reconnecting client gets the missed ones with the
+// Last-Event-ID header, "?last_id=0" replays all the kept ones.
+func eventsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.Header().Set("Content-Type", "application/json")
+		http.Error(w, string(errJson.MethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	var types map[string]bool
+	if q := r.URL.Query().Get("types"); q != "" {
+		types = map[string]bool{}
+		for _, t := range strings.Split(q, ",") {
+			types[strings.TrimSpace(t)] = true
+		}
+	}
+
+	last := r.Header.Get("Last-Event-ID")
+	if last == "" {
+		last = r.URL.Query().Get("last_id")
+	}
+	lastId, err := strconv.ParseUint(last, 10, 64)
+	sub := events.Subscribe(lastId, err == nil)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	write := func(e events.Event) error {
+		if types != nil && !types[e.Type] {
+			return nil
+		}
+		data, err := json.Marshal(e)
+		if err != nil {
+			mlog.LogFatalStackError(err)
+		}
+		_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.Id, e.Type, data)
+		return err
+	}
+
+	for _, e := range sub.Backlog {
+		if write(e) != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e := <-sub.C:
+			if write(e) != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"miningPoolCli/utils/events"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// streamIds reads the ids of the first n events of the stream
+func streamIds(t *testing.T, url string, header http.Header, n int) []string {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var ids []string
+	scanner := bufio.NewScanner(resp.Body)
+	for len(ids) < n && scanner.Scan() {
+		if id := strings.TrimPrefix(scanner.Text(), "id: "); id != scanner.Text() {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func TestEventsReplay(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(eventsHandler))
+	defer srv.Close()
+
+	sub := events.Subscribe(0, false)
+	for _, typ := range []string{events.TaskSwitch, events.ShareFound, events.Hashrate, events.ShareAccepted} {
+		events.Publish(typ, 0, nil)
+	}
+	base := (<-sub.C).Id
+	sub.Close()
+	id := func(n uint64) string { return strconv.FormatUint(base+n, 10) }
+
+	tests := []struct {
+		name   string
+		query  string
+		header http.Header
+		want   []string
+	}{
+		{"last event id", "", http.Header{"Last-Event-Id": {id(1)}}, []string{id(2), id(3)}},
+		{"last_id query", "?last_id=" + id(2), nil, []string{id(3)}},
+		{"header before query", "?last_id=" + id(0), http.Header{"Last-Event-Id": {id(2)}}, []string{id(3)}},
+		{"types filter", "?types=share_found,share_accepted&last_id=" + strconv.FormatUint(base-1, 10), nil, []string{id(1), id(3)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := streamIds(t, srv.URL+tt.query, tt.header, len(tt.want))
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("ids %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"miningPoolCli/utils/export"
+	"miningPoolCli/utils/gpuwrk"
+	"miningPoolCli/utils/mlog"
+	"net/http"
+	"strings"
+)
+
+// exportHandler serves "/export/<format>", the stats in the format of a
+// rig OS, e.g. for the RaveOS miner API. Like the stats subcommand it
+// reports empty stats when they are older than export.MaxAge.
+func exportHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != "GET" && r.Method != "POST" {
+		http.Error(w, string(errJson.MethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	format := strings.TrimPrefix(r.URL.Path, "/export/")
+	if _, ok := export.Exporters[format]; !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	stats, ok := gpuwrk.LatestStats()
+	if !ok || export.Stale(stats, export.MaxAge) {
+		stats = gpuwrk.Stats{}
+	}
+	data, err := export.Render(format, stats)
+	if err != nil {
+		mlog.LogFatalStackError(err)
+	}
+	w.Write(data)
+}
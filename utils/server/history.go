@@ -0,0 +1,86 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"miningPoolCli/utils/gpuwrk"
+	"miningPoolCli/utils/history"
+	"miningPoolCli/utils/mlog"
+	"net/http"
+	"strconv"
+)
+
+func errBadParam(name, value string) error {
+	return errors.New("invalid " + name + " \"" + value + "\"")
+}
+
+type gpuHistory struct {
+	Index   int              `json:"index"` // as in /stat
+	Key     string           `json:"key"`   // backend@bus id, stable across restarts
+	Model   string           `json:"device_name"`
+	Samples []history.Sample `json:"samples"`
+}
+
+// historyHandler serves the hashrate, shares and restarts of the gpus over
+// time: "?resolution=1s" (last hour, default) or "1m" (last day),
+// "?since=<unix time>" and "?gpu=<index>" limit it
+func historyHandler(gpuData *[]*gpuwrk.GpuGoroutine) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Method != "GET" {
+			http.Error(w, errJson.MethodNotAllowed, http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := r.URL.Query()
+		resolution := history.Second
+		if name := query.Get("resolution"); name != "" {
+			var ok bool
+			if resolution, ok = history.Resolutions[name]; !ok {
+				writeResult(w, errBadParam("resolution", name), http.StatusBadRequest)
+				return
+			}
+		}
+		since, _ := strconv.ParseInt(query.Get("since"), 10, 64)
+		index := -1
+		if s := query.Get("gpu"); s != "" {
+			var err error
+			if index, err = strconv.Atoi(s); err != nil || index < 0 {
+				writeResult(w, errBadParam("gpu", s), http.StatusBadRequest)
+				return
+			}
+		}
+
+		resp := struct {
+			Resolution string       `json:"resolution"`
+			Step       int64        `json:"step"` // seconds per sample
+			Gpus       []gpuHistory `json:"gpus"`
+		}{
+			Resolution: resolution.Name,
+			Step:       resolution.Step,
+			Gpus:       []gpuHistory{},
+		}
+
+		gpuwrk.WorkersMu.RLock()
+		for i, g := range *gpuData {
+			if index >= 0 && i != index {
+				continue
+			}
+			key := gpuwrk.Key(g.GpuData)
+			resp.Gpus = append(resp.Gpus, gpuHistory{
+				Index:   i,
+				Key:     key,
+				Model:   g.GpuData.Model,
+				Samples: history.Samples(key, resolution, since),
+			})
+		}
+		gpuwrk.WorkersMu.RUnlock()
+
+		jsonResp, err := json.Marshal(resp)
+		if err != nil {
+			mlog.LogFatalStackError(err)
+		}
+		w.Write(jsonResp)
+	}
+}
@@ -0,0 +1,29 @@
+package server
+
+import (
+	"encoding/json"
+	"miningPoolCli/utils/mlog"
+	"net/http"
+	"strconv"
+)
+
+// logsHandler serves the last log messages, "?n=" of them
+func logsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != "GET" {
+		http.Error(w, errJson.MethodNotAllowed, http.StatusMethodNotAllowed)
+		return
+	}
+
+	n, _ := strconv.Atoi(r.URL.Query().Get("n"))
+	resp := struct {
+		Lines []string `json:"lines"`
+	}{Lines: mlog.Recent(n)}
+
+	jsonResp, err := json.Marshal(resp)
+	if err != nil {
+		mlog.LogFatalStackError(err)
+	}
+	w.Write(jsonResp)
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"miningPoolCli/config"
+	"miningPoolCli/utils/gpuwrk"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// metricsHandler serves the stats in the Prometheus text format
+func metricsHandler(gpuData *[]*gpuwrk.GpuGoroutine) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			w.Header().Set("Content-Type", "application/json")
+			http.Error(w, string(errJson.MethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		var buf bytes.Buffer
+		metric := func(name, help string) {
+			fmt.Fprintf(&buf, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+		}
+
+		metric("miningpoolcli_uptime_seconds", "Time since the client started.")
+		fmt.Fprintf(&buf, "miningpoolcli_uptime_seconds %d\n", time.Now().Unix()-config.StartProgramTimestamp)
+
+		gpuwrk.WorkersMu.RLock()
+		gpus := make([]*gpuwrk.GpuGoroutine, 0, len(*gpuData))
+		for _, g := range *gpuData {
+			gpus = append(gpus, &gpuwrk.GpuGoroutine{
+				GpuData:         g.GpuData,
+				CurrentHashrate: g.CurrentHashrate,
+				Telemetry:       g.Telemetry,
+			})
+		}
+		gpuwrk.WorkersMu.RUnlock()
+
+		labels := func(i int, g *gpuwrk.GpuGoroutine) string {
+			return fmt.Sprintf(`{gpu="%d",backend=%q,model=%q,bus_id=%q}`,
+				i, g.GpuData.Backend, g.GpuData.Model, g.GpuData.BusId)
+		}
+		series := []struct {
+			name, help string
+			value      func(g *gpuwrk.GpuGoroutine) int
+		}{
+			{"miningpoolcli_gpu_hashrate_mhs", "Hashrate of the gpu in Mh/s.",
+				func(g *gpuwrk.GpuGoroutine) int { return g.CurrentHashrate }},
+			{"miningpoolcli_gpu_temperature_celsius", "Gpu temperature, 0 if unknown.",
+				func(g *gpuwrk.GpuGoroutine) int { return g.Telemetry.Temp }},
+			{"miningpoolcli_gpu_fan_percent", "Gpu fan speed, 0 if unknown.",
+				func(g *gpuwrk.GpuGoroutine) int { return g.Telemetry.Fan }},
+			{"miningpoolcli_gpu_power_watts", "Gpu power draw, 0 if unknown.",
+				func(g *gpuwrk.GpuGoroutine) int { return g.Telemetry.Power }},
+		}
+		for _, s := range series {
+			metric(s.name, s.help)
+			for i, g := range gpus {
+				buf.WriteString(s.name + labels(i, g) + " " + strconv.Itoa(s.value(g)) + "\n")
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		w.Write(buf.Bytes())
+	}
+}
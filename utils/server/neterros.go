@@ -2,6 +2,10 @@ package server
 
 var errJson = struct {
 	MethodNotAllowed string
+	Unauthorized     string
+	Forbidden        string
 }{
 	MethodNotAllowed: `{"error": "StatusMethodNotAllowed", "code": 405}`,
+	Unauthorized:     `{"error": "StatusUnauthorized", "code": 401}`,
+	Forbidden:        `{"error": "StatusForbidden", "code": 403}`,
 }
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"encoding/json"
+	"miningPoolCli/utils/gpuwrk"
+	"miningPoolCli/utils/mlog"
+	"net/http"
+)
+
+func rediscoverHandler(control Control) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mlog.LogInfo("Received /rediscover HTTP request")
+		result, err := control.Rediscover()
+		if err != nil {
+			writeResult(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		resp := struct {
+			Status    bool               `json:"status"`
+			Started   []gpuwrk.GPUstruct `json:"started"`
+			Restarted []gpuwrk.GPUstruct `json:"restarted"`
+			Retired   []gpuwrk.GPUstruct `json:"retired"`
+		}{Status: true}
+		for _, g := range result.Started {
+			resp.Started = append(resp.Started, g.GpuData)
+		}
+		for _, g := range result.Restarted {
+			resp.Restarted = append(resp.Restarted, g.GpuData)
+		}
+		for _, g := range result.Retired {
+			resp.Retired = append(resp.Retired, g.GpuData)
+		}
+
+		jsonResp, err := json.Marshal(resp)
+		if err != nil {
+			mlog.LogFatalStackError(err)
+		}
+		w.Write(jsonResp)
+	}
+}
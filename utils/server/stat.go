@@ -4,17 +4,28 @@ import (
 	"encoding/json"
 	"miningPoolCli/config"
 	"miningPoolCli/utils/gpuwrk"
+	"miningPoolCli/utils/history"
 	"miningPoolCli/utils/mlog"
+	"miningPoolCli/utils/telemetry"
 	"net/http"
 	"time"
 )
 
 type info struct {
-	Hashrate int `json:"hashrate"`
+	Hashrate int    `json:"hashrate"`
+	Avg1m    int    `json:"hashrate_1m"` // average hashrate over the last minute
+	Avg15m   int    `json:"hashrate_15m"`
+	Avg1h    int    `json:"hashrate_1h"`
+	Thermal  string `json:"thermal,omitempty"` // "paused" or "throttled" by the thermal guard
+	State    string `json:"state"`             // gpuwrk.State*
+	TaskId   int    `json:"task_id,omitempty"` // task of the running miner
+	Accepted int    `json:"accepted"`          // shares
+	Rejected int    `json:"rejected"`
 	gpuwrk.GPUstruct
+	telemetry.Reading
 }
 
-func statHandler(gpuData *[]gpuwrk.GpuGoroutine) http.HandlerFunc {
+func statHandler(gpuData *[]*gpuwrk.GpuGoroutine) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 
@@ -25,21 +36,52 @@ func statHandler(gpuData *[]gpuwrk.GpuGoroutine) http.HandlerFunc {
 
 		var resp = struct {
 			Status        bool   `json:"status"`
+			State         string `json:"state"` // of all gpus, "mixed" if they differ
 			MinerUptime   int64  `json:"miner_uptime"`
 			TotalHashrate int    `json:"total_hashrate"`
+			TotalAvg1m    int    `json:"total_hashrate_1m"`
+			TotalAvg15m   int    `json:"total_hashrate_15m"`
+			TotalAvg1h    int    `json:"total_hashrate_1h"`
 			Gpus          []info `json:"gpus"`
 		}{
 			Status:      true,
 			MinerUptime: time.Now().Unix() - config.StartProgramTimestamp,
 		}
 
+		gpuwrk.WorkersMu.RLock()
+		defer gpuwrk.WorkersMu.RUnlock()
+
 		for i := 0; i < len(*gpuData); i++ {
 			g := (*gpuData)[i]
-			resp.Gpus = append(resp.Gpus, info{
+			taskId, running := g.RunningTask()
+			if !running {
+				taskId = 0
+			}
+			key := gpuwrk.Key(g.GpuData)
+			gpu := info{
 				GPUstruct: g.GpuData,
 				Hashrate:  g.CurrentHashrate,
-			})
+				Avg1m:     history.Average(key, time.Minute),
+				Avg15m:    history.Average(key, 15*time.Minute),
+				Avg1h:     history.Average(key, time.Hour),
+				Reading:   g.Telemetry,
+				Thermal:   g.Thermal,
+				State:     g.State(),
+				TaskId:    taskId,
+				Accepted:  g.Accepted,
+				Rejected:  g.Rejected,
+			}
+			resp.Gpus = append(resp.Gpus, gpu)
 			resp.TotalHashrate += g.CurrentHashrate
+			resp.TotalAvg1m += gpu.Avg1m
+			resp.TotalAvg15m += gpu.Avg15m
+			resp.TotalAvg1h += gpu.Avg1h
+
+			if state := g.State(); resp.State == "" {
+				resp.State = state
+			} else if resp.State != state {
+				resp.State = "mixed"
+			}
 		}
 
 		jsonResp, err := json.Marshal(resp)
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"encoding/json"
+	"miningPoolCli/utils/mlog"
+	"net/http"
+	"time"
+)
+
+type taskInfo struct {
+	Id         int    `json:"id"`
+	Giver      string `json:"giver"`
+	Complexity string `json:"complexity"`
+	Expire     int64  `json:"expire"`
+	ExpiresIn  int64  `json:"expires_in"` // seconds, negative once expired
+}
+
+// tasksHandler serves the pool's current tasks
+func tasksHandler(control Control) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Method != "GET" {
+			http.Error(w, errJson.MethodNotAllowed, http.StatusMethodNotAllowed)
+			return
+		}
+
+		now := time.Now().Unix()
+		tasks := []taskInfo{}
+		for _, task := range control.Tasks() {
+			tasks = append(tasks, taskInfo{
+				Id:         task.Id,
+				Giver:      task.Giver,
+				Complexity: task.Complexity,
+				Expire:     task.Expire,
+				ExpiresIn:  task.Expire - now,
+			})
+		}
+
+		jsonResp, err := json.Marshal(tasks)
+		if err != nil {
+			mlog.LogFatalStackError(err)
+		}
+		w.Write(jsonResp)
+	}
+}
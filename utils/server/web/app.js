@@ -0,0 +1,272 @@
+// Dashboard of the -serve-stat server: polls /stat, /tasks and /logs, loads
+// the hashrate chart from /history and follows /events for new samples and
+// the shares.
+"use strict";
+
+const chartSpan = 10 * 60 * 1000; // ms of hashrate kept in the chart
+const maxShares = 50;
+const colors = ["#5fd38d", "#58a6ff", "#f0c05a", "#ff6b6b", "#c58af9", "#4dd4d4", "#ff9f5a", "#a3be8c"];
+
+const samples = []; // {time, hs: []}
+const shares = [];  // share events, newest first
+let tasks = [];
+
+const $ = (id) => document.getElementById(id);
+
+function el(tag, text, className) {
+  const e = document.createElement(tag);
+  if (text !== undefined) e.textContent = text;
+  if (className) e.className = className;
+  return e;
+}
+
+function duration(seconds) {
+  const sign = seconds < 0 ? "-" : "";
+  seconds = Math.abs(seconds);
+  const h = Math.floor(seconds / 3600);
+  const m = Math.floor(seconds / 60) % 60;
+  const s = seconds % 60;
+  if (h > 0) return sign + h + "h " + m + "m";
+  if (m > 0) return sign + m + "m " + s + "s";
+  return sign + s + "s";
+}
+
+function sensor(value, unit) {
+  return value ? value + unit : "-";
+}
+
+function showError(message) {
+  $("error").textContent = message || "";
+}
+
+async function getJson(path) {
+  const resp = await fetch(path, {cache: "no-store"});
+  if (!resp.ok) throw new Error(path + ": " + resp.status + " " + resp.statusText);
+  return resp.json();
+}
+
+async function control(path) {
+  try {
+    const resp = await fetch(path, {method: "POST"});
+    const body = await resp.json().catch(() => ({}));
+    if (!resp.ok) throw new Error(path + ": " + (body.error || resp.status));
+    showError("");
+    refreshStat();
+  } catch (e) {
+    showError(e.message);
+  }
+}
+
+function gpuActions(index) {
+  const td = el("td");
+  td.className = "actions";
+  for (const action of ["pause", "resume", "restart", "drain"]) {
+    const b = el("button", action);
+    b.onclick = () => control("gpu/" + index + "/" + action);
+    td.appendChild(b);
+  }
+  return td;
+}
+
+async function refreshStat() {
+  let stat;
+  try {
+    stat = await getJson("stat");
+  } catch (e) {
+    showError(e.message);
+    return;
+  }
+
+  $("state").textContent = stat.state || "-";
+  $("state").className = "state-" + stat.state;
+  $("uptime").textContent = duration(stat.miner_uptime);
+  $("total").textContent = "~" + stat.total_hashrate + " Mh/s";
+  $("averages").textContent = stat.total_hashrate_1m + " / " + stat.total_hashrate_15m + " / " +
+    stat.total_hashrate_1h + " Mh/s";
+
+  const body = $("gpus");
+  body.textContent = "";
+  (stat.gpus || []).forEach((g, i) => {
+    const tr = el("tr");
+    tr.appendChild(el("td", i));
+    tr.appendChild(el("td", g.device_name));
+    tr.appendChild(el("td", g.backend));
+    tr.appendChild(el("td", g.bus_id || "-"));
+    tr.appendChild(el("td", g.state, "state-" + g.state));
+    tr.appendChild(el("td", g.hashrate + " Mh/s"));
+    tr.appendChild(el("td", sensor(g.temp, "°C")));
+    tr.appendChild(el("td", sensor(g.fan, "%")));
+    tr.appendChild(el("td", sensor(g.power, " W")));
+    tr.appendChild(el("td", g.accepted + " / " + g.rejected));
+    tr.appendChild(el("td", g.task_id || "-"));
+    tr.appendChild(gpuActions(i));
+    body.appendChild(tr);
+  });
+}
+
+async function refreshTasks() {
+  try {
+    tasks = await getJson("tasks");
+  } catch (e) {
+    return;
+  }
+  renderTasks();
+}
+
+function renderTasks() {
+  const now = Date.now() / 1000;
+  const body = $("tasks");
+  body.textContent = "";
+  for (const t of tasks) {
+    const tr = el("tr");
+    tr.appendChild(el("td", t.id));
+    tr.appendChild(el("td", t.giver));
+    tr.appendChild(el("td", duration(Math.round(t.expire - now))));
+    body.appendChild(tr);
+  }
+}
+
+async function refreshLog() {
+  let resp;
+  try {
+    resp = await getJson("logs?n=100");
+  } catch (e) {
+    return;
+  }
+  const log = $("log");
+  const atBottom = log.scrollTop + log.clientHeight >= log.scrollHeight - 4;
+  log.textContent = resp.lines.join("\n");
+  if (atBottom) log.scrollTop = log.scrollHeight;
+}
+
+function renderShares() {
+  const body = $("shares");
+  body.textContent = "";
+  for (const e of shares) {
+    const tr = el("tr");
+    const result = e.type.replace("share_", "");
+    tr.appendChild(el("td", new Date(e.time).toLocaleTimeString()));
+    tr.appendChild(el("td", e.gpu));
+    tr.appendChild(el("td", e.data && e.data.task_id));
+    tr.appendChild(el("td", result, result));
+    body.appendChild(tr);
+  }
+}
+
+function renderChart() {
+  const canvas = $("chart");
+  const dpr = window.devicePixelRatio || 1;
+  const width = canvas.clientWidth, height = canvas.clientHeight;
+  canvas.width = width * dpr;
+  canvas.height = height * dpr;
+
+  const ctx = canvas.getContext("2d");
+  ctx.scale(dpr, dpr);
+  ctx.clearRect(0, 0, width, height);
+
+  const now = Date.now();
+  while (samples.length && samples[0].time < now - chartSpan) samples.shift();
+
+  let max = 1, gpus = 0;
+  for (const s of samples) {
+    gpus = Math.max(gpus, s.hs.length);
+    for (const h of s.hs) max = Math.max(max, h);
+  }
+  max *= 1.1;
+
+  const pad = 36;
+  const x = (t) => pad + (width - pad) * (1 - (now - t) / chartSpan);
+  const y = (h) => height - 16 - (height - 24) * h / max;
+
+  ctx.strokeStyle = "#2b2f37";
+  ctx.fillStyle = "#8a909b";
+  ctx.font = "11px system-ui, sans-serif";
+  for (let i = 0; i <= 4; i++) {
+    const h = max * i / 4;
+    ctx.beginPath();
+    ctx.moveTo(pad, y(h));
+    ctx.lineTo(width, y(h));
+    ctx.stroke();
+    ctx.fillText(Math.round(h), 2, y(h) + 4);
+  }
+
+  const legend = $("legend");
+  legend.textContent = "";
+  for (let g = 0; g < gpus; g++) {
+    const color = colors[g % colors.length];
+    ctx.strokeStyle = color;
+    ctx.lineWidth = 1.5;
+    ctx.beginPath();
+    let started = false;
+    for (const s of samples) {
+      if (g >= s.hs.length) continue;
+      if (started) ctx.lineTo(x(s.time), y(s.hs[g]));
+      else ctx.moveTo(x(s.time), y(s.hs[g]));
+      started = true;
+    }
+    ctx.stroke();
+
+    const item = el("span", "GPU " + g);
+    item.style.color = color;
+    legend.appendChild(item);
+  }
+}
+
+async function loadHistory() {
+  const since = Math.floor((Date.now() - chartSpan) / 1000);
+  let resp;
+  try {
+    resp = await getJson("history?since=" + since);
+  } catch (e) {
+    return;
+  }
+
+  const byTime = new Map();
+  for (const g of resp.gpus) {
+    for (const s of g.samples) {
+      if (!byTime.has(s.time)) byTime.set(s.time, []);
+      byTime.get(s.time)[g.index] = s.hashrate;
+    }
+  }
+  for (const time of [...byTime.keys()].sort((a, b) => a - b)) {
+    const hs = Array.from(byTime.get(time), (h) => h || 0);
+    samples.push({time: time * 1000, hs: hs});
+  }
+}
+
+function handleEvent(e) {
+  if (e.type === "hashrate") {
+    // the replayed events overlap the history
+    const last = samples.length ? samples[samples.length - 1].time : 0;
+    if (e.time < last + 1000) return;
+    samples.push({time: e.time, hs: e.data.hs || []});
+  } else {
+    shares.unshift(e);
+    if (shares.length > maxShares) shares.pop();
+    renderShares();
+    refreshStat();
+  }
+}
+
+function followEvents() {
+  const types = "hashrate,share_found,share_accepted,share_rejected";
+  const source = new EventSource("events?last_id=0&types=" + types);
+  for (const type of types.split(",")) {
+    source.addEventListener(type, (msg) => handleEvent(JSON.parse(msg.data)));
+  }
+}
+
+document.querySelectorAll("#rig-actions button").forEach((b) => {
+  b.onclick = () => control(b.dataset.action);
+});
+
+refreshStat();
+refreshTasks();
+refreshLog();
+loadHistory().then(followEvents);
+
+setInterval(refreshStat, 2000);
+setInterval(refreshTasks, 5000);
+setInterval(refreshLog, 5000);
+setInterval(renderTasks, 1000);
+setInterval(renderChart, 1000);
@@ -0,0 +1,99 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package telemetry
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Hwmon reads the hwmon sensors of the gpus in sysRoot/class/drm, as exposed
+// by amdgpu and nouveau: temp1_input (m°C), pwm1 / pwm1_max and
+// power1_average or power1_input (µW).
+func Hwmon(sysRoot string) (map[string]Reading, error) {
+	cards, err := filepath.Glob(filepath.Join(sysRoot, "class", "drm", "card*"))
+	if err != nil {
+		return nil, err
+	}
+
+	readings := map[string]Reading{}
+	for _, card := range cards {
+		if strings.Contains(filepath.Base(card), "-") {
+			continue // connectors, "card0-DP-1"
+		}
+
+		device := filepath.Join(card, "device")
+		busId := cardBusId(device)
+		if busId == "" {
+			continue
+		}
+
+		hwmons, _ := filepath.Glob(filepath.Join(device, "hwmon", "hwmon*"))
+		for _, hwmon := range hwmons {
+			var r Reading
+			if t, ok := readInt(hwmon, "temp1_input"); ok {
+				r.Temp = int((t + 500) / 1000)
+			}
+			if pwm, ok := readInt(hwmon, "pwm1"); ok {
+				max, ok := readInt(hwmon, "pwm1_max")
+				if !ok || max <= 0 {
+					max = 255
+				}
+				r.Fan = int((pwm*100 + max/2) / max)
+			}
+			if p, ok := readInt(hwmon, "power1_average"); ok {
+				r.Power = int((p + 500000) / 1000000)
+			} else if p, ok := readInt(hwmon, "power1_input"); ok {
+				r.Power = int((p + 500000) / 1000000)
+			}
+			readings[busId] = readings[busId].merge(r)
+		}
+	}
+	return readings, nil
+}
+
+// cardBusId takes the bus id from the uevent of the device, or from the
+// name of the PCI device the symlink points to
+func cardBusId(device string) string {
+	if data, err := ioutil.ReadFile(filepath.Join(device, "uevent")); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if strings.HasPrefix(line, "PCI_SLOT_NAME=") {
+				return busKey(strings.TrimPrefix(line, "PCI_SLOT_NAME="))
+			}
+		}
+	}
+	if target, err := filepath.EvalSymlinks(device); err == nil {
+		return busKey(filepath.Base(target))
+	}
+	return ""
+}
+
+func readInt(dir, name string) (int64, bool) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	return n, err == nil
+}
@@ -0,0 +1,148 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package telemetry
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var nvidiaSmiQuery = []string{
+	"--query-gpu=index,pci.bus_id,temperature.gpu,fan.speed,power.draw",
+	"--format=csv,noheader,nounits",
+}
+
+func (c Collector) nvidiaSmi() (map[string]Reading, error) {
+	out, err := c.run(c.NvidiaSmi, nvidiaSmiQuery...)
+	if err != nil || out == nil {
+		return nil, err
+	}
+	return ParseNvidiaSmi(out)
+}
+
+// ParseNvidiaSmi parses the csv printed for nvidiaSmiQuery:
+//
+//	0, 00000000:01:00.0, 54, 40, 120.35
+//
+// A reading is stored by bus id and by NvidiaIndexKey. Values nvidia-smi
+// doesn't know ("[N/A]", "[Not Supported]") are left 0.
+func ParseNvidiaSmi(out []byte) (map[string]Reading, error) {
+	r := csv.NewReader(strings.NewReader(string(out)))
+	r.TrimLeadingSpace = true
+	r.FieldsPerRecord = -1
+
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, errors.New("nvidia-smi: " + err.Error())
+	}
+
+	readings := map[string]Reading{}
+	for _, rec := range records {
+		if len(rec) < 5 {
+			continue
+		}
+		r := Reading{
+			Temp:  number(rec[2]),
+			Fan:   number(rec[3]),
+			Power: number(rec[4]),
+		}
+		if index, err := strconv.Atoi(strings.TrimSpace(rec[0])); err == nil {
+			readings[NvidiaIndexKey(index)] = r
+		}
+		if busId := busKey(rec[1]); busId != "" {
+			readings[busId] = r
+		}
+	}
+	return readings, nil
+}
+
+var rocmSmiArgs = []string{"--showtemp", "--showfan", "--showpower", "--showbus", "--json"}
+
+func (c Collector) rocmSmi() (map[string]Reading, error) {
+	out, err := c.run(c.RocmSmi, rocmSmiArgs...)
+	if err != nil || out == nil {
+		return nil, err
+	}
+	return ParseRocmSmi(out)
+}
+
+// ParseRocmSmi parses the json printed for rocmSmiArgs, an object per card:
+//
+//	{"card0": {"PCI Bus": "0000:03:00.0", "Temperature (Sensor edge) (C)": "45.0",
+//	 "Fan speed (%)": "30", "Average Graphics Package Power (W)": "95.0"}}
+//
+// The key names differ between rocm-smi versions, so they are matched loosely.
+func ParseRocmSmi(out []byte) (map[string]Reading, error) {
+	var cards map[string]map[string]interface{}
+	if err := json.Unmarshal(out, &cards); err != nil {
+		return nil, errors.New("rocm-smi: " + err.Error())
+	}
+
+	readings := map[string]Reading{}
+	for name, fields := range cards {
+		if !strings.HasPrefix(name, "card") {
+			continue // "system" and other non-card entries
+		}
+
+		var busId string
+		var r Reading
+		edgeTemp := false
+		for key, value := range fields {
+			s, ok := value.(string)
+			if !ok {
+				continue
+			}
+			switch k := strings.ToLower(key); {
+			case strings.HasPrefix(k, "pci bus"):
+				busId = busKey(s)
+			case strings.HasPrefix(k, "temperature"):
+				// prefer the edge sensor, the hottest junction otherwise
+				edge := strings.Contains(k, "edge")
+				if t := number(s); edge || (!edgeTemp && t > r.Temp) {
+					r.Temp = t
+					edgeTemp = edgeTemp || edge
+				}
+			case strings.HasPrefix(k, "fan speed (%)"):
+				r.Fan = number(s)
+			case strings.Contains(k, "power (w)"):
+				r.Power = number(s)
+			}
+		}
+		if busId != "" {
+			readings[busId] = r
+		}
+	}
+	return readings, nil
+}
+
+// number rounds a decimal value, 0 if it isn't one
+func number(s string) int {
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0
+	}
+	return int(math.Round(f))
+}
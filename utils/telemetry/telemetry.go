@@ -0,0 +1,122 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package telemetry reads the temperature, fan speed and power draw of the
+// gpus from nvidia-smi, rocm-smi and the sysfs hwmon sensors.
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"miningPoolCli/utils/pci"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+// Reading of the sensors of one gpu, 0 if unknown
+type Reading struct {
+	Temp  int `json:"temp"`  // °C
+	Fan   int `json:"fan"`   // %
+	Power int `json:"power"` // W
+}
+
+// merge fills the fields of r that other knows and r doesn't
+func (r Reading) merge(other Reading) Reading {
+	if r.Temp == 0 {
+		r.Temp = other.Temp
+	}
+	if r.Fan == 0 {
+		r.Fan = other.Fan
+	}
+	if r.Power == 0 {
+		r.Power = other.Power
+	}
+	return r
+}
+
+// Collector knows where to read the sensors from. An empty command path
+// disables that source; SysRoot is "/sys" except in tests.
+type Collector struct {
+	NvidiaSmi string
+	RocmSmi   string
+	SysRoot   string
+	Timeout   time.Duration // per command
+}
+
+// NvidiaIndexKey is the key of the nvidia-smi reading of the gpu at index,
+// for gpus without a known bus id. nvidia-smi numbers the gpus in PCI order.
+func NvidiaIndexKey(index int) string {
+	return "nvidia:" + strconv.Itoa(index)
+}
+
+// Collect reads all sources and returns the readings by PCI bus id in the
+// "0000:01:00.0" form, the nvidia-smi ones also by NvidiaIndexKey. The smi tools take precedence over hwmon. Sources that
+// are not installed are skipped silently; other failures are returned, the
+// readings of the remaining sources are still used.
+func (c Collector) Collect() (map[string]Reading, []error) {
+	readings := map[string]Reading{}
+	var errs []error
+
+	add := func(found map[string]Reading, err error) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+		for busId, r := range found {
+			readings[busId] = readings[busId].merge(r)
+		}
+	}
+
+	if c.NvidiaSmi != "" {
+		add(c.nvidiaSmi())
+	}
+	if c.RocmSmi != "" {
+		add(c.rocmSmi())
+	}
+	if c.SysRoot != "" {
+		add(Hwmon(c.SysRoot))
+	}
+
+	return readings, errs
+}
+
+// run executes a command, a missing executable is not an error
+func (c Collector) run(name string, args ...string) ([]byte, error) {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, name, args...).Output()
+	if errors.Is(err, exec.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.New(name + ": " + err.Error())
+	}
+	return out, nil
+}
+
+func busKey(s string) string {
+	return pci.NormalizeBusId(s)
+}
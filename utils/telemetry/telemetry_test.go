@@ -0,0 +1,154 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package telemetry
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseNvidiaSmi(t *testing.T) {
+	out := []byte("0, 00000000:01:00.0, 54, 40, 120.35\n" +
+		"1, 00000000:02:00.0, 61, [N/A], [Not Supported]\n" +
+		"2, [N/A], 70, 55, 200\n" +
+		"garbage\n")
+
+	got, err := ParseNvidiaSmi(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]Reading{
+		"0000:01:00.0": {Temp: 54, Fan: 40, Power: 120},
+		"0000:02:00.0": {Temp: 61},
+		"nvidia:0":     {Temp: 54, Fan: 40, Power: 120},
+		"nvidia:1":     {Temp: 61},
+		"nvidia:2":     {Temp: 70, Fan: 55, Power: 200},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseNvidiaSmi = %v, want %v", got, want)
+	}
+}
+
+func TestParseRocmSmi(t *testing.T) {
+	out := []byte(`{
+		"card0": {"PCI Bus": "0000:03:00.0", "Temperature (Sensor junction) (C)": "70.0",
+			"Temperature (Sensor edge) (C)": "45.0", "Fan speed (%)": "30",
+			"Average Graphics Package Power (W)": "95.4"},
+		"card1": {"PCI Bus": "0000:04:00.0", "Temperature (Sensor junction) (C)": "66.0",
+			"Temperature (Sensor memory) (C)": "72.0"},
+		"card2": {"Fan speed (%)": "10"},
+		"system": {"Driver version": "6.2.4"}
+	}`)
+
+	got, err := ParseRocmSmi(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]Reading{
+		"0000:03:00.0": {Temp: 45, Fan: 30, Power: 95},
+		"0000:04:00.0": {Temp: 72},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseRocmSmi = %v, want %v", got, want)
+	}
+
+	if _, err := ParseRocmSmi([]byte("not json")); err == nil {
+		t.Error("ParseRocmSmi accepted invalid json")
+	}
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestHwmon(t *testing.T) {
+	root := t.TempDir()
+	drm := filepath.Join("class", "drm")
+	writeFiles(t, root, map[string]string{
+		// bus id from uevent, power1_average preferred
+		filepath.Join(drm, "card0", "device", "uevent"):                            "DRIVER=amdgpu\nPCI_SLOT_NAME=0000:03:00.0\n",
+		filepath.Join(drm, "card0", "device", "hwmon", "hwmon2", "temp1_input"):    "45500\n",
+		filepath.Join(drm, "card0", "device", "hwmon", "hwmon2", "pwm1"):           "128\n",
+		filepath.Join(drm, "card0", "device", "hwmon", "hwmon2", "pwm1_max"):       "255\n",
+		filepath.Join(drm, "card0", "device", "hwmon", "hwmon2", "power1_average"): "95400000\n",
+		filepath.Join(drm, "card0", "device", "hwmon", "hwmon2", "power1_input"):   "1000000\n",
+		// connector, skipped
+		filepath.Join(drm, "card0-DP-1", "device", "uevent"): "PCI_SLOT_NAME=0000:09:00.0\n",
+		// no pwm1_max, power1_input only
+		filepath.Join(drm, "card1", "device", "uevent"):                          "PCI_SLOT_NAME=0000:04:00.0\n",
+		filepath.Join(drm, "card1", "device", "hwmon", "hwmon3", "pwm1"):         "51\n",
+		filepath.Join(drm, "card1", "device", "hwmon", "hwmon3", "power1_input"): "120000000\n",
+	})
+
+	// bus id from the name of the PCI device the symlink points to
+	pciDev := filepath.Join(root, "devices", "pci0000:00", "0000:05:00.0")
+	writeFiles(t, pciDev, map[string]string{
+		filepath.Join("hwmon", "hwmon4", "temp1_input"): "60000\n",
+	})
+	if err := os.MkdirAll(filepath.Join(root, drm, "card2"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(pciDev, filepath.Join(root, drm, "card2", "device")); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Hwmon(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]Reading{
+		"0000:03:00.0": {Temp: 46, Fan: 50, Power: 95},
+		"0000:04:00.0": {Fan: 20, Power: 120},
+		"0000:05:00.0": {Temp: 60},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Hwmon = %v, want %v", got, want)
+	}
+}
+
+func TestCollectSysfsOnly(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		filepath.Join("class", "drm", "card0", "device", "uevent"):                         "PCI_SLOT_NAME=0000:03:00.0\n",
+		filepath.Join("class", "drm", "card0", "device", "hwmon", "hwmon0", "temp1_input"): "50000\n",
+	})
+
+	got, errs := Collector{SysRoot: root}.Collect()
+	if len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	if got["0000:03:00.0"].Temp != 50 {
+		t.Errorf("Collect = %v, want 50°C for 0000:03:00.0", got)
+	}
+}
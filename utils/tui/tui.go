@@ -0,0 +1,337 @@
+/*
+miningPoolCli – open-source tonuniverse mining pool client
+
+Copyright (C) 2021 tonuniverse.com
+
+This file is part of miningPoolCli.
+
+miningPoolCli is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+miningPoolCli is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with miningPoolCli.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package tui is the -tui terminal dashboard: a table of the gpus, the log
+// and keys to control the miners
+package tui
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"miningPoolCli/config"
+	"miningPoolCli/utils/gpuwrk"
+	"miningPoolCli/utils/mlog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+const (
+	altScreen  = "\x1b[?1049h\x1b[?25l" // alternate screen, hidden cursor
+	mainScreen = "\x1b[?25h\x1b[?1049l"
+	home       = "\x1b[H"
+	clearLine  = "\x1b[K"
+	clearBelow = "\x1b[J"
+	reverse    = "\x1b[7m"
+	bold       = "\x1b[1m"
+	reset      = "\x1b[0m"
+)
+
+const help = "↑/↓ select  p pause  r resume  x restart  d drain  " +
+	"P/R/X/D all GPUs  PgUp/PgDn scroll log  q quit"
+
+// Action applies a control action to the gpu at index, -1 for all of them
+type Action func(action string, index int) error
+
+type ui struct {
+	sync.Mutex
+	gpus   *[]*gpuwrk.GpuGoroutine
+	action Action
+
+	selected int    // gpu the keys act on
+	scroll   int    // log lines scrolled back from the newest
+	status   string // result of the last action
+
+	done        chan struct{} // closed when the terminal is restored
+	restoreOnce sync.Once
+	restore     func()
+}
+
+// Start switches the terminal to the dashboard. q or Ctrl+C stops the
+// miners and exits, the terminal is restored through mlog.AtExit so SIGTERM
+// does it as well. It fails if stdin / stdout are not a terminal.
+func Start(gpus *[]*gpuwrk.GpuGoroutine, action Action) error {
+	in, out := int(os.Stdin.Fd()), int(os.Stdout.Fd())
+	if !term.IsTerminal(in) || !term.IsTerminal(out) {
+		return errors.New("-tui needs a terminal")
+	}
+
+	state, err := term.MakeRaw(in)
+	if err != nil {
+		return err
+	}
+
+	u := &ui{gpus: gpus, action: action, done: make(chan struct{})}
+	u.restore = func() {
+		os.Stdout.WriteString(mainScreen)
+		term.Restore(in, state)
+		mlog.SetOutput(os.Stdout)
+	}
+	mlog.AtExit(u.close)
+
+	mlog.SetOutput(ioutil.Discard)
+	os.Stdout.WriteString(altScreen)
+
+	go u.readKeys()
+	go func() {
+		for {
+			u.draw()
+			select {
+			case <-u.done:
+				return
+			case <-time.After(time.Second):
+			}
+		}
+	}()
+	return nil
+}
+
+// close stops drawing and restores the terminal. Taking the lock waits for
+// a draw in progress, so it can't write over the main screen.
+func (u *ui) close() {
+	u.restoreOnce.Do(func() {
+		u.Lock()
+		close(u.done)
+		u.Unlock()
+		u.restore()
+	})
+}
+
+func (u *ui) quit() {
+	u.close()
+	mlog.LogInfo("Stopping the miners")
+	gpuwrk.KillAll(u.gpus)
+	mlog.Exit(0)
+}
+
+func (u *ui) readKeys() {
+	buf := make([]byte, 64)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return
+		}
+		u.handleKey(string(buf[:n]))
+		u.draw()
+	}
+}
+
+func (u *ui) handleKey(key string) {
+	switch key {
+	case "q", "\x03": // Ctrl+C doesn't send SIGINT in raw mode
+		u.quit()
+	case "\x1b[A", "k":
+		u.move(-1)
+	case "\x1b[B", "j":
+		u.move(1)
+	case "\x1b[5~":
+		u.scrollLog(10)
+	case "\x1b[6~":
+		u.scrollLog(-10)
+	case "p", "r", "x", "d":
+		u.control(key, u.current())
+	case "P", "R", "X", "D":
+		u.control(strings.ToLower(key), -1)
+	}
+}
+
+func (u *ui) current() int {
+	u.Lock()
+	defer u.Unlock()
+	return u.selected
+}
+
+func (u *ui) move(delta int) {
+	gpuwrk.WorkersMu.RLock()
+	count := len(*u.gpus)
+	gpuwrk.WorkersMu.RUnlock()
+
+	u.Lock()
+	defer u.Unlock()
+	u.selected += delta
+	if u.selected >= count {
+		u.selected = count - 1
+	}
+	if u.selected < 0 {
+		u.selected = 0
+	}
+}
+
+func (u *ui) scrollLog(delta int) {
+	u.Lock()
+	defer u.Unlock()
+	u.scroll += delta
+	if u.scroll < 0 {
+		u.scroll = 0
+	}
+}
+
+var keyActions = map[string]string{
+	"p": "pause",
+	"r": "resume",
+	"x": "restart",
+	"d": "drain",
+}
+
+func (u *ui) control(key string, index int) {
+	action := keyActions[key]
+	target := "all GPUs"
+	if index >= 0 {
+		target = "GPU " + strconv.Itoa(index)
+	}
+
+	status := action + " " + target + ": ok"
+	if err := u.action(action, index); err != nil {
+		status = action + " " + target + ": " + err.Error()
+	}
+
+	u.Lock()
+	u.status = status
+	u.Unlock()
+}
+
+// table renders the gpu rows, the first line is the header
+func (u *ui) table() (lines []string, total int, state string) {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  #\tMODEL\tBACKEND\tSTATE\tHASHRATE\tSHARES\tTASK\tTEMP\tFAN\tPOWER")
+
+	gpuwrk.WorkersMu.RLock()
+	for i, g := range *u.gpus {
+		task := "-"
+		if taskId, running := g.RunningTask(); running {
+			task = strconv.Itoa(taskId)
+		}
+		fmt.Fprintf(w, "  %d\t%s\t%s\t%s\t%d Mh\t%d/%d\t%s\t%s\t%s\t%s\n",
+			i, g.GpuData.Model, g.GpuData.Backend, g.State(), g.CurrentHashrate,
+			g.Accepted, g.Rejected, task,
+			gpuwrk.FormatSensor(g.Telemetry.Temp, "°C"), gpuwrk.FormatSensor(g.Telemetry.Fan, "%"), gpuwrk.FormatSensor(g.Telemetry.Power, " W"))
+
+		total += g.CurrentHashrate
+		if s := g.State(); state == "" {
+			state = s
+		} else if state != s {
+			state = "mixed"
+		}
+	}
+	gpuwrk.WorkersMu.RUnlock()
+
+	w.Flush()
+	return strings.Split(strings.TrimRight(buf.String(), "\n"), "\n"), total, state
+}
+
+// fit cuts s to the terminal width
+func fit(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	return string([]rune(s)[:width])
+}
+
+func (u *ui) draw() {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width < 20 || height < 8 {
+		return
+	}
+
+	rows, total, state := u.table()
+	uptime := time.Duration(time.Now().Unix()-config.StartProgramTimestamp) * time.Second
+
+	u.Lock()
+	defer u.Unlock()
+	select {
+	case <-u.done:
+		return
+	default:
+	}
+	if u.selected >= len(rows)-1 {
+		u.selected = len(rows) - 2
+	}
+	if u.selected < 0 {
+		u.selected = 0
+	}
+
+	var lines []string
+	lines = append(lines, bold+fit(fmt.Sprintf("miningPoolCli v%s  state %s  up %s  total ~%d Mh",
+		config.BuildVersion, state, uptime, total), width)+reset, "")
+	for i, row := range rows {
+		row = fit(row, width)
+		switch {
+		case i == 0:
+			row = bold + row + reset
+		case i-1 == u.selected:
+			row = reverse + ">" + strings.TrimPrefix(row, " ") + reset
+		}
+		lines = append(lines, row)
+	}
+	lines = append(lines, "", bold+fit("Log"+strings.Repeat("─", width), width)+reset)
+
+	// the log pane takes the space left above the status and help lines
+	logHeight := height - len(lines) - 2
+	if logHeight < 1 {
+		logHeight = 1
+	}
+	var log []string
+	for _, message := range mlog.Recent(0) {
+		log = append(log, strings.Split(strings.TrimRight(message, "\n"), "\n")...)
+	}
+	if maxScroll := len(log) - logHeight; u.scroll > maxScroll {
+		u.scroll = maxScroll
+	}
+	if u.scroll < 0 {
+		u.scroll = 0
+	}
+	end := len(log) - u.scroll
+	start := end - logHeight
+	if start < 0 {
+		start = 0
+	}
+	for _, line := range log[start:end] {
+		lines = append(lines, fit(line, width))
+	}
+	for len(lines) < height-2 {
+		lines = append(lines, "")
+	}
+
+	lines = append(lines, fit(u.status, width), fit(help, width))
+
+	var buf bytes.Buffer
+	buf.WriteString(home)
+	for i, line := range lines {
+		if i >= height {
+			break
+		}
+		buf.WriteString(line + clearLine)
+		if i < height-1 {
+			buf.WriteString("\r\n")
+		}
+	}
+	buf.WriteString(clearBelow)
+	os.Stdout.Write(buf.Bytes())
+}